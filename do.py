@@ -250,6 +250,8 @@ def testgo():
     ret = run(
         ["go test ./... -v -coverprofile coverage.txt"], capture_output=True
     )
+    # the Api is expected to be safe for concurrent use
+    run(["go test -race -run Concurrent ."], capture_output=True)
     os.chdir("..")
     result = re.findall(r"coverage:.*\s(\d+)", ret)
     result = [x for x in result if int(x) != 0 and int(x) < 100]
@@ -101,12 +101,20 @@ class ControllerRoute(object):
     def method(self) -> str:
         return self._method
 
+    @property
+    def operation_id(self) -> str:
+        return self._obj["operationId"]
+
     @property
     def operation_name(self) -> str:
         name = self._obj["operationId"]
         name = util.pascal_case(name)
         return name
 
+    @property
+    def client_stream(self) -> bool:
+        return self._obj.get("x-stream") == "client"
+
     @property
     def route_parameters(self) -> [str]:
         return self._parameters
@@ -79,6 +79,13 @@ class GoServerControllerGenerator(object):
         w.write_line(
             "handler interfaces.{name}".format(name=ctrl.service_handler_name)
         )
+        if self._has_client_stream(ctrl):
+            w.write_line(
+                "// uploads keeps the partial bodies of the resumable uploads",
+                "uploads *{models_prefix}UploadStore".format(
+                    models_prefix=self._ctx.models_prefix
+                ),
+            )
         w.pop_indent()
         w.write_line("}", "")
 
@@ -90,11 +97,23 @@ class GoServerControllerGenerator(object):
                 handle_name=ctrl.service_handler_name,
             )
         ).push_indent()
-        w.write_line(
-            "return &{name}{{handler}}".format(name=self._struct_name(ctrl))
-        ).pop_indent()
+        if self._has_client_stream(ctrl):
+            w.write_line(
+                "return &{name}{{handler: handler, uploads: {models_prefix}NewUploadStore()}}".format(
+                    name=self._struct_name(ctrl),
+                    models_prefix=self._ctx.models_prefix,
+                )
+            ).pop_indent()
+        else:
+            w.write_line(
+                "return &{name}{{handler}}".format(name=self._struct_name(ctrl))
+            ).pop_indent()
         w.write_line("}", "")
 
+    def _has_client_stream(self, ctrl):
+        # type: (ctx.Controller) -> bool
+        return any(route.client_stream for route in ctrl.routes)
+
     def _write_routes(self, w, ctrl):
         # type: (Writer, ctx.Controller) -> None
         w.write_line(
@@ -105,8 +124,11 @@ class GoServerControllerGenerator(object):
         w.write_line("return [] httpapi.Route {").push_indent()
         for r in ctrl.routes:
             w.write_line(
-                """{{ Path: "{url}", Method: "{method}", Name: "{operation_name}", Handler: ctrl.{operation_name}}},""".format(
-                    url=r.url, method=r.method, operation_name=r.operation_name
+                """{{ Path: "{url}", Method: "{method}", Name: "{operation_name}", OperationId: "{operation_id}", Handler: ctrl.{operation_name}}},""".format(
+                    url=r.url,
+                    method=r.method,
+                    operation_name=r.operation_name,
+                    operation_id=r.operation_id,
                 )
             )
         w.pop_indent()
@@ -150,6 +172,13 @@ class GoServerControllerGenerator(object):
             )
         )
         w.push_indent()
+        if route.client_stream:
+            w.write_line(
+                """// a resumable upload is received across the requests carrying its upload id
+                if !ctrl.uploads.ReceiveRequest(w, r) {
+                    return
+                }"""
+            )
         request_body = route.requestBody()  # type: ctx.Component
         rsp_error = "response{}Error".format(route.operation_name)
         rsp_errors = ["default"]
@@ -161,8 +190,10 @@ class GoServerControllerGenerator(object):
             w.write_line(
                 """var item {full_modelname}
                 if r.Body != nil {{
+                    // the body is read until its end whether it has a content length
+                    // or is sent using chunked transfer encoding
                     body, readError := io.ReadAll(r.Body)
-                    if body != nil {{
+                    if readError == nil {{
                         item = {new_modelname}()
                         err := item.Unmarshal().FromJson(string(body))
                         if err != nil {{
@@ -170,6 +201,12 @@ class GoServerControllerGenerator(object):
                             return
                         }}
                     }} else {{
+                        // a decompressed body exceeding httpapi.MaxDecompressedBodyBytes is refused
+                        var tooLarge *http.MaxBytesError
+                        if errors.As(readError, &tooLarge) {{
+                            _, _ = httpapi.WriteDefaultResponse(w, http.StatusRequestEntityTooLarge)
+                            return
+                        }}
                         ctrl.{rsp_400_error}(w, "validation", readError)
                         return
                     }}
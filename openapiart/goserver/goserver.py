@@ -94,3 +94,13 @@ class GoServerGenerator(object):
             os.path.join(srcfolder, name), os.path.join(output_path, name)
         )
         print("copy: " + os.path.join(output_path, name))
+        name = "compression.go"
+        shutil.copyfile(
+            os.path.join(srcfolder, name), os.path.join(output_path, name)
+        )
+        print("copy: " + os.path.join(output_path, name))
+        name = "tracing.go"
+        shutil.copyfile(
+            os.path.join(srcfolder, name), os.path.join(output_path, name)
+        )
+        print("copy: " + os.path.join(output_path, name))
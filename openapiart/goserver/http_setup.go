@@ -9,10 +9,12 @@ import (
 
 // Route defines the parameters for an api endpoint.
 type Route struct {
-	Name    string
-	Method  string
-	Path    string
-	Handler http.HandlerFunc
+	Name   string
+	Method string
+	Path   string
+	// OperationId is the operationId of the route in the spec, Name is used in its place when empty
+	OperationId string
+	Handler     http.HandlerFunc
 }
 
 // Controller creates a set of HTTP routes.
@@ -22,6 +24,8 @@ type HttpController interface {
 
 // AppendRoutes appends the routes of one or more Controllers to a mux.Router.
 // If a nil router is passed, a new router will be created here.
+// The handlers of the routes transparently decompress requests and compress responses,
+// and serve every request within a server span named after the operationId of its route.
 func AppendRoutes(router *mux.Router, controllers ...HttpController) *mux.Router {
 	if router == nil {
 		router = mux.NewRouter()
@@ -29,11 +33,15 @@ func AppendRoutes(router *mux.Router, controllers ...HttpController) *mux.Router
 
 	for _, controller := range controllers {
 		for _, route := range controller.Routes() {
+			operation := route.OperationId
+			if operation == "" {
+				operation = route.Name
+			}
 			router.
 				Methods(route.Method).
 				Path(route.Path).
 				Name(route.Name).
-				Handler(route.Handler)
+				Handler(TracingHandler(operation, route.Path, CompressionHandler(route.Handler)))
 		}
 	}
 	return router
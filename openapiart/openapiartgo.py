@@ -20,6 +20,15 @@ class FluentRpc(object):
     def __init__(self):
         self.operation_name = None
         self.method = None
+        self.ctx_method = None
+        self.ctx_description = None
+        self.args = ""
+        self.request_type = None
+        self.security = None
+        self.http_method = None
+        self.http_request = None
+        self.reader_method = None
+        self.reader_description = None
         self.request = "emptypb.Empty{}"
         self.responses = []
         self.http_call = None
@@ -287,7 +296,6 @@ class OpenApiArtGo(OpenApiArtPlugin):
         self._write(line)
         self._write('import "google.golang.org/protobuf/types/known/emptypb"')
         self._write('import "google.golang.org/grpc"')
-        self._write('import "google.golang.org/grpc/credentials/insecure"')
         self._write('import "github.com/ghodss/yaml"')
         self._write('import "google.golang.org/protobuf/encoding/protojson"')
         self._write('import "google.golang.org/protobuf/proto"')
@@ -447,6 +455,13 @@ class OpenApiArtGo(OpenApiArtPlugin):
                     "// streaming method for " + rpc.operation_name
                 )
                 rpc.description = self._get_description(path_item_object, True)
+                rpc.security = path_item_object.get(
+                    "security", self._openapi.get("security")
+                )
+                rpc.http_method = str(
+                    operation_id.context.path.fields[0]
+                ).upper()
+                rpc.http_url = http_url
                 http.operation_name = self._get_external_struct_name(
                     operation_id.value
                 )
@@ -594,12 +609,22 @@ class OpenApiArtGo(OpenApiArtPlugin):
                         interface=new.interface,
                         request_return_type=rpc.request_return_type,
                     )
+                    rpc.ctx_method = """{operation_name}Ctx(ctx context.Context, {struct} {interface}) ({request_return_type}, error)""".format(
+                        operation_name=rpc.operation_name,
+                        struct=new.struct,
+                        interface=new.interface,
+                        request_return_type=rpc.request_return_type,
+                    )
+                    rpc.args = new.struct
+                    rpc.request_type = new.interface
                     rpc.validate = """
                         if err := {struct}.validate(); err != nil {{
+                            api.validationFailed(ctx, "{operation_name}")
                             return nil, err
                         }}
                     """.format(
-                        struct=new.struct
+                        struct=new.struct,
+                        operation_name=rpc.operation_name,
                     )
                     # TODO: restore this behavior in optimized way
                     # rpc.log_request = (
@@ -613,30 +638,31 @@ class OpenApiArtGo(OpenApiArtPlugin):
                     #     struct=new.struct
                     # )
                     # rpc.log_request += '\nlogs.Debug().RawJSON("Request", []byte(jsonStr)).Msg("")\n'
-                    rpc.http_call = (
-                        """return api.http{operation_name}({struct})""".format(
-                            operation_name=rpc.operation_name,
-                            struct=new.struct,
-                        )
+                    rpc.http_call = """return api.http{operation_name}(ctx, {struct})""".format(
+                        operation_name=rpc.operation_name,
+                        struct=new.struct,
                     )
                     if url.startswith("/"):
                         url = url[1:]
                     http.request = """{struct}Json, err := {struct}.Marshal().ToJson()
                     if err != nil {{return nil, err}}
-                    parentCtx := api.Telemetry().getRootContext()
-                    newCtx, span := api.Telemetry().NewSpan(parentCtx, "{operation_name}", trace.WithSpanKind(trace.SpanKindClient))
-                    defer api.Telemetry().CloseSpan(span)
-                    resp, err := api.httpSendRecv(newCtx, "{url}", {struct}Json, "{method}", false)
+                    resp, err := api.httpSendRecv(ctx, "{operation_name}", "{url}", {struct}Json, "{method}", false, {chunked})
                     """.format(
                         url=http_url,
                         struct=new.struct,
                         operation_name=rpc.operation_name,
+                        chunked="true"
+                        if rpc.streaming_type == "client"
+                        else "false",
                         method=str(
                             operation_id.context.path.fields[0]
                         ).upper(),
                     )
-                    http.method = """http{rpc_method}""".format(
-                        rpc_method=rpc.method
+                    http.method = """http{operation_name}(ctx context.Context, {struct} {interface}) ({request_return_type}, error)""".format(
+                        operation_name=rpc.operation_name,
+                        struct=new.struct,
+                        interface=new.interface,
+                        request_return_type=rpc.request_return_type,
                     )
                 else:
                     rpc.description = "// {} {}".format(
@@ -660,30 +686,42 @@ class OpenApiArtGo(OpenApiArtPlugin):
                         request_return_type=rpc.request_return_type,
                         param="data []byte" if rpc.octet_bytes else "",
                     )
+                    rpc.ctx_method = """{operation_name}Ctx(ctx context.Context{param}) ({request_return_type}, error)""".format(
+                        operation_name=rpc.operation_name,
+                        request_return_type=rpc.request_return_type,
+                        param=", data []byte" if rpc.octet_bytes else "",
+                    )
+                    rpc.args = "data" if rpc.octet_bytes else ""
+                    rpc.request_type = "[]byte" if rpc.octet_bytes else None
                     # rpc.log_request = (
                     #     'logs.Info("Executing %s")' % rpc.operation_name
                     # )
-                    rpc.http_call = (
-                        """return api.http{operation_name}({value})""".format(
-                            operation_name=rpc.operation_name,
-                            value="data" if rpc.octet_bytes else "",
-                        )
+                    rpc.http_call = """return api.http{operation_name}(ctx{value})""".format(
+                        operation_name=rpc.operation_name,
+                        value=", data" if rpc.octet_bytes else "",
                     )
-                    http.request = """parentCtx := api.Telemetry().getRootContext()
-                    newCtx, span := api.Telemetry().NewSpan(parentCtx, "{operation_name}", trace.WithSpanKind(trace.SpanKindClient))
-                    defer api.Telemetry().CloseSpan(span)
-                    resp, err := api.httpSendRecv(newCtx, "{url}", {val}, "{method}", {stream})""".format(
+                    http.request = """resp, err := api.httpSendRecv(ctx, "{operation_name}", "{url}", {val}, "{method}", {stream}, {chunked})""".format(
                         url=http_url,
                         operation_name=rpc.operation_name,
+                        chunked="true"
+                        if rpc.streaming_type == "client"
+                        else "false",
                         method=str(
                             operation_id.context.path.fields[0]
                         ).upper(),
                         val="string(data)" if rpc.octet_bytes else '""',
                         stream="true" if rpc.octet_bytes else "false",
                     )
-                    http.method = """http{rpc_method}""".format(
-                        rpc_method=rpc.method
+                    http.method = """http{operation_name}(ctx context.Context{param}) ({request_return_type}, error)""".format(
+                        operation_name=rpc.operation_name,
+                        request_return_type=rpc.request_return_type,
+                        param=", data []byte" if rpc.octet_bytes else "",
                     )
+                rpc.http_request = http.request
+                rpc.ctx_description = """// {operation_name}Ctx is the same as {operation_name} but uses ctx for
+                // cancellation, deadlines and tracing of the call""".format(
+                    operation_name=rpc.operation_name
+                )
                 if rpc.streaming_type and rpc.streaming_type == "client":
                     rpc.stream_method = """{operation_name}(context.Context, []byte) (*{pkg}.{request_return_type}, error)""".format(
                         operation_name=rpc.stream_operation_name,
@@ -691,6 +729,25 @@ class OpenApiArtGo(OpenApiArtPlugin):
                         request_return_type=rpc.streaming_response,
                     )
                 elif rpc.streaming_type and rpc.streaming_type == "server":
+                    rpc.reader_method = """{operation_name}Stream({params}) (io.ReadCloser, error)""".format(
+                        operation_name=rpc.operation_name,
+                        params=rpc.ctx_method[
+                            rpc.ctx_method.index("(")
+                            + 1 : rpc.ctx_method.index(")")
+                        ],
+                    )
+                    if rpc.request_return_type == "[]byte":
+                        encoding = "the response bytes"
+                    else:
+                        encoding = (
+                            "the response encoded as protobuf over grpc and as json over http"
+                        )
+                    rpc.reader_description = """// {operation_name}Stream returns a reader yielding {encoding}
+                    // chunk by chunk as they are received, the next chunk is only requested once the
+                    // previous one has been read; cancelling ctx or closing the reader aborts the stream""".format(
+                        operation_name=rpc.operation_name,
+                        encoding=encoding,
+                    )
                     rpc.stream_method = """{operation_name}(context.Context, {request}) ({request_return_type}, error)""".format(
                         operation_name=rpc.stream_operation_name,
                         request_return_type=rpc.request_return_type,
@@ -794,14 +851,36 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 return rErr
             }}
 
+            // versionMeta holds the remote version and the result of the version check
+            // of every location as the servers of the locations may run different versions
             type versionMeta struct {{
-                checkVersion  bool
-                localVersion  Version
-                remoteVersion Version
-                checkError    error
-                clientName    string
-                clientAppVer  string
-                serverName    string
+                mutex          sync.Mutex
+                checkVersion   bool
+                localVersion   Version
+                remoteVersions map[string]Version
+                checked        map[string]bool
+                checkErrors    map[string]error
+                clientName     string
+                clientAppVer   string
+                serverName     string
+            }}
+
+            func newVersionMeta() *versionMeta {{
+                return &versionMeta{{
+                    remoteVersions: map[string]Version{{}},
+                    checked:        map[string]bool{{}},
+                    checkErrors:    map[string]error{{}},
+                }}
+            }}
+
+            // forget drops what is known about the version of location so that it is
+            // checked again once the location can be reached
+            func (meta *versionMeta) forget(location string) {{
+                meta.mutex.Lock()
+                defer meta.mutex.Unlock()
+                delete(meta.remoteVersions, location)
+                delete(meta.checked, location)
+                delete(meta.checkErrors, location)
             }}
             type {internal_struct_name} struct {{
                 apiSt
@@ -812,21 +891,13 @@ class OpenApiArtGo(OpenApiArtPlugin):
 
             // grpcConnect builds up a grpc connection
             func (api *{internal_struct_name}) grpcConnect() error {{
+                api.mutex.Lock()
+                defer api.mutex.Unlock()
                 if api.grpcClient == nil {{
                     if api.grpc.clientConnection == nil {{
-                        ctx, cancelFunc := context.WithTimeout(context.Background(), api.grpc.dialTimeout)
-                        defer cancelFunc()
-                        var opts []grpc.DialOption
-                        opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-                        if api.Telemetry().isOTLPEnabled() {{
-                            opts = append(opts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
-                        }}
-                        conn, err := grpc.DialContext(ctx, api.grpc.location, opts...)
-                        if err != nil {{
-                            return err
-                        }}
-                        api.grpcClient = {pb_pkg_name}.New{proto_service}Client(conn)
-                        api.grpc.clientConnection = conn
+                        // the connection to a location is dialed once a request is sent to it
+                        api.grpc.connections = newGrpcConnections(api.grpc, api.grpcDial)
+                        api.grpcClient = {pb_pkg_name}.New{proto_service}Client(api.grpc.connections)
                     }} else {{
                         api.grpcClient = {pb_pkg_name}.New{proto_service}Client(api.grpc.clientConnection)
                     }}
@@ -834,69 +905,167 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 return nil
             }}
 
+            // grpcDial dials the grpc connection to location
+            func (api *{internal_struct_name}) grpcDial(location string) (*grpc.ClientConn, error) {{
+                ctx, cancelFunc := context.WithTimeout(context.Background(), api.grpc.dialTimeout)
+                defer cancelFunc()
+                creds, err := api.grpc.transportCredentials()
+                if err != nil {{
+                    return nil, err
+                }}
+                var opts []grpc.DialOption
+                opts = append(opts, grpc.WithTransportCredentials(creds))
+                if api.grpc.hasTLS() {{
+                    // block until the handshake completes so that certificate
+                    // errors are reported here instead of as unavailable rpcs
+                    opts = append(opts, grpc.WithReturnConnectionError())
+                }}
+                if api.Telemetry().isOTLPEnabled() {{
+                    opts = append(opts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+                }}
+                // the messages are measured when the metrics of the operations are recorded
+                opts = append(opts, grpc.WithStatsHandler(grpcMetricsHandler{{}}))
+                // the calls sent to the server are not sent again to another location unless idempotent
+                opts = append(opts, grpc.WithStatsHandler(grpcDeliveryHandler{{}}))
+                if api.grpc.keepAlive.Time > 0 {{
+                    opts = append(opts, grpc.WithKeepaliveParams(api.grpc.keepAlive))
+                }}
+                if loopback := api.grpc.loopback; loopback != nil {{
+                    // the connection of a loopback transport is made in memory
+                    opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {{
+                        return loopback(ctx)
+                    }}))
+                }}
+                conn, err := grpc.DialContext(ctx, location, opts...)
+                if err != nil {{
+                    if api.grpc.hasTLS() {{
+                        return nil, fmt.Errorf("tls connection to %s failed: %v", location, err)
+                    }}
+                    return nil, err
+                }}
+                return conn, nil
+            }}
+
+            // grpcClose closes every connection of the transport and returns the first error
             func (api *{internal_struct_name}) grpcClose() error {{
+                var err error
                 if api.grpc != nil {{
                     if api.grpc.clientConnection != nil {{
-                        err := api.grpc.clientConnection.Close()
-                        if err != nil {{
-                            return err
+                        err = api.grpc.clientConnection.Close()
+                    }}
+                    if api.grpc.connections != nil {{
+                        if closeErr := api.grpc.connections.close(); closeErr != nil && err == nil {{
+                            err = closeErr
                         }}
                     }}
                 }}
                 api.grpcClient = nil
                 api.grpc = nil
-                return nil
+                return err
+            }}
+
+            // httpClose drains the connection pool, the connections of requests in flight
+            // are closed by the pool once their response has been read
+            func (api *{internal_struct_name}) httpClose() {{
+                if api.httpClient.transport != nil {{
+                    api.httpClient.transport.CloseIdleConnections()
+                }}
+                api.httpClient = httpClient{{}}
+                api.http = nil
             }}
 
+            // Close releases the connections of the transport, closing an already closed Api has no effect.
+            // Everything is released even when a step fails and the first error is returned.
             func (api *{internal_struct_name}) Close() error {{
+                err := api.StopRecording()
+                defer api.closeLoopback()
+                api.mutex.Lock()
+                defer api.mutex.Unlock()
                 if api.hasGrpcTransport() {{
-                    err := api.grpcClose()
-                    return err
+                    if closeErr := api.grpcClose(); closeErr != nil && err == nil {{
+                        err = closeErr
+                    }}
                 }}
                 if api.hasHttpTransport() {{
-                    err := api.http.conn.(*net.TCPConn).SetLinger(0)
-                    api.http.conn.Close()
-                    api.http.conn = nil
-                    api.http = nil
-                    api.httpClient.client = nil
+                    api.httpClose()
+                }}
+                return err
+            }}
+
+            // WaitUntilReady blocks until a location of the transport is ready to serve requests or ctx is done
+            func (api *{internal_struct_name}) WaitUntilReady(ctx context.Context) error {{
+                if api.hasHttpTransport() {{
+                    return api.http.waitUntilReady(ctx)
+                }}
+                if !api.hasGrpcTransport() {{
+                    // replayed and fake calls are always answered
+                    return nil
+                }}
+                if err := api.grpcConnect(); err != nil {{
                     return err
                 }}
-                return nil
+                return api.grpc.waitUntilReady(ctx)
             }}
 
             //  NewApi returns a new instance of the top level interface hierarchy
             func NewApi() Api {{
+                return newApi()
+            }}
+
+            func newApi() *{internal_struct_name} {{
                 api := {internal_struct_name}{{}}
                 api.tracer = &telemetry{{transport: "HTTP", serviceName: "go-snappi"}}
-                api.versionMeta = &versionMeta{{checkVersion: false}}
+                api.versionMeta = newVersionMeta()
+                api.locationFailed = api.versionMeta.forget
+                api.security = operationSecurity
+                api.idempotent = idempotentOperations
+                api.cassette = apiCassetteCodec
+                api.wire = apiWireOperations
                 return &api
             }}
 
             // httpConnect builds up a http connection
             func (api *{internal_struct_name}) httpConnect() error {{
+                api.mutex.Lock()
+                defer api.mutex.Unlock()
                 if api.httpClient.client == nil {{
+                    transport := api.http
+                    tlsConfig, err := transport.clientTLSConfig()
+                    if err != nil {{
+                        return err
+                    }}
+                    dialer := &net.Dialer{{KeepAlive: transport.keepAlive}}
+                    dialContext := dialer.DialContext
+                    if transport.loopback != nil {{
+                        // the connections of a loopback transport are made in memory
+                        dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {{
+                            return transport.loopback(ctx)
+                        }}
+                    }}
                     tr := http.Transport{{
+                        MaxIdleConns:        transport.maxIdleConns,
+                        MaxIdleConnsPerHost: transport.maxIdleConnsPerHost,
+                        MaxConnsPerHost:     transport.maxConnsPerHost,
+                        IdleConnTimeout:     transport.idleConnTimeout,
                         DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {{
-                            tcpConn, err := (&net.Dialer{{}}).DialContext(ctx, network, addr)
+                            tcpConn, err := dialContext(ctx, network, addr)
                             if err != nil {{
                                 return nil, err
                             }}
-                            tlsConn := tls.Client(tcpConn, &tls.Config{{InsecureSkipVerify: !api.http.verify}})
+                            config := tlsConfig
+                            if config.ServerName == "" {{
+                                config = tlsConfig.Clone()
+                                config.ServerName, _, _ = net.SplitHostPort(addr)
+                            }}
+                            tlsConn := tls.Client(tcpConn, config)
                             err = tlsConn.Handshake()
                             if err != nil {{
-                                return nil, err
+                                _ = tcpConn.Close()
+                                return nil, fmt.Errorf("tls handshake with %s failed: %v", addr, err)
                             }}
-                            api.http.conn = tcpConn
                             return tlsConn, nil
                         }},
-                        DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {{
-                            tcpConn, err := (&net.Dialer{{}}).DialContext(ctx, network, addr)
-                            if err != nil {{
-                                return nil, err
-                            }}
-                            api.http.conn = tcpConn
-                            return tcpConn, nil
-                        }},
+                        DialContext: dialContext,
                     }}
 
                     var client httpClient
@@ -905,14 +1074,16 @@ class OpenApiArtGo(OpenApiArtPlugin):
                             client: &http.Client{{
                                 Transport: otelhttp.NewTransport(&tr),
                             }},
-                            ctx: api.Telemetry().getRootContext(),
+                            transport: &tr,
+                            ctx:       api.Telemetry().getRootContext(),
                         }}
                     }} else {{
                         client = httpClient{{
                             client: &http.Client{{
                                 Transport: &tr,
                             }},
-                            ctx: context.Background(),
+                            transport: &tr,
+                            ctx:       context.Background(),
                         }}
                     }}
 
@@ -921,31 +1092,137 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 return nil
             }}
 
-            func (api *{internal_struct_name}) httpSendRecv(ctx context.Context, urlPath string, jsonBody string, method string, isBytes bool) (*http.Response, error) {{
+            func (api *{internal_struct_name}) httpSendRecv(ctx context.Context, operation string, urlPath string, jsonBody string, method string, isBytes bool, chunked bool) (*http.Response, error) {{
                 err := api.httpConnect()
                 if err != nil {{
                     return nil, err
                 }}
                 httpClient := api.httpClient
-                var bodyReader = bytes.NewReader([]byte(jsonBody))
-                queryUrl, err := url.Parse(api.http.location)
+                if ctx == nil {{
+                    ctx = httpClient.ctx
+                }}
+                queryUrl, err := url.Parse(api.http.endpoints.requestLocation(ctx))
                 if err != nil {{
                     return nil, err
                 }}
                 queryUrl, _ = queryUrl.Parse(urlPath)
-                req, _ := http.NewRequest(method, queryUrl.String(), bodyReader)
-                if isBytes {{
-                    req.Header.Set("Content-Type", "application/octet-stream")
-                }} else {{
-                    req.Header.Set("Content-Type", "application/json")
+                streamed := chunked && api.http.enableHttpStreaming
+                var payload []byte
+                contentEncoding := ""
+                if !streamed && len(jsonBody) > 0 {{
+                    if payload, contentEncoding, err = api.http.compressedBody([]byte(jsonBody)); err != nil {{
+                        return nil, err
+                    }}
                 }}
-                if ctx != nil {{
+                // a resumable upload sends the body from the offset acknowledged by the server,
+                // which is queried by a request carrying the upload id without any offset
+                uploadId := ""
+                if streamed && api.http.uploadRestarts > 0 {{
+                    uploadId = newUploadId()
+                }}
+                var offset uint64
+                var response *http.Response
+                send := func(ctx context.Context, query bool) error {{
+                    if response != nil {{
+                        // discard the retryable response of the previous attempt
+                        _, _ = io.Copy(io.Discard, response.Body)
+                        response.Body.Close()
+                        response = nil
+                    }}
+                    var body io.Reader = bytes.NewReader(payload)
+                    if query {{
+                        body = http.NoBody
+                    }} else if streamed {{
+                        // the body is piped as it is sent, its unknown length makes it sent with chunked transfer encoding
+                        reader := &chunkReader{{
+                            reader:    strings.NewReader(jsonBody[offset:]),
+                            chunkSize: api.http.chunkSize,
+                            progress:  api.http.uploadProgress,
+                            operation: operation,
+                            total:     uint64(len(jsonBody)),
+                            sent:      offset,
+                            ctx:       ctx,
+                        }}
+                        if api.http.chunkSize > 0 {{
+                            reader.chunk = int(offset / api.http.chunkSize)
+                        }}
+                        body, contentEncoding = api.http.streamedBody(reader)
+                    }}
+                    req, err := http.NewRequest(method, queryUrl.String(), body)
+                    if err != nil {{
+                        return err
+                    }}
+                    if isBytes {{
+                        req.Header.Set("Content-Type", "application/octet-stream")
+                    }} else {{
+                        req.Header.Set("Content-Type", "application/json")
+                    }}
+                    if contentEncoding != "" && !query {{
+                        req.Header.Set("Content-Encoding", contentEncoding)
+                    }}
+                    if api.http.compression != CompressionNone {{
+                        // the response is decompressed below instead of by the http client
+                        req.Header.Set("Accept-Encoding", api.http.compression)
+                    }}
+                    if uploadId != "" {{
+                        req.Header.Set(UploadIdMetadataKey, uploadId)
+                        if !query {{
+                            req.Header.Set(UploadOffsetMetadataKey, strconv.FormatUint(offset, 10))
+                        }}
+                    }}
                     req = req.WithContext(ctx)
+                    if err := api.setHttpCredentials(ctx, operation, req); err != nil {{
+                        return err
+                    }}
+                    resp, err := httpClient.client.Do(req)
+                    if err != nil {{
+                        return err
+                    }}
+                    response = resp
+                    if uploadId != "" && (query || resp.StatusCode == http.StatusConflict) {{
+                        if offset, err = uploadOffset(resp.Header.Values(UploadOffsetMetadataKey), len(jsonBody)); err != nil {{
+                            return err
+                        }}
+                        if !query {{
+                            return errUploadConflict
+                        }}
+                        return nil
+                    }}
+                    if api.http.retryPolicy.retryableStatus(resp.StatusCode) {{
+                        return errRetryableStatus
+                    }}
+                    return nil
+                }}
+                if uploadId != "" {{
+                    err = api.http.resumeUpload(ctx, operation, func(ctx context.Context, restart bool) error {{
+                        if restart {{
+                            if err := send(ctx, true); err != nil {{
+                                return err
+                            }}
+                        }}
+                        return send(ctx, false)
+                    }})
                 }} else {{
-                    req = req.WithContext(httpClient.ctx)
+                    err = api.http.retry(ctx, operation, api.idempotent[operation], func(ctx context.Context) error {{
+                        return send(ctx, false)
+                    }})
+                }}
+                if err == errUploadConflict {{
+                    // the restarts are exhausted, the conflicting response is handled by the caller
+                    err = nil
                 }}
-                response, err := httpClient.client.Do(req)
-                return response, err
+                if err == errRetryableStatus {{
+                    // the retries are exhausted, the last response is handled by the caller
+                    err = nil
+                }}
+                if err != nil {{
+                    return response, err
+                }}
+                if err := decompressResponse(response); err != nil {{
+                    return nil, err
+                }}
+                measureHttpResponse(ctx, len(jsonBody), response)
+                return response, nil
             }}
             """.format(
                 internal_struct_name=self._api.internal_struct_name,
@@ -961,10 +1238,15 @@ class OpenApiArtGo(OpenApiArtPlugin):
         for rpc in self._api.external_rpc_methods:
             methods.append(rpc.description)
             methods.append(rpc.method)
+            methods.append(rpc.ctx_description)
+            methods.append(rpc.ctx_method)
             # adding func signature in interface for stream of rpcs
             if rpc.streaming_type is not None:
                 methods.append(rpc.stream_description)
                 methods.append(rpc.stream_method)
+            if rpc.reader_method is not None:
+                methods.append(rpc.reader_description)
+                methods.append(rpc.reader_method)
             # descriptions.append("(*{}).{}".format(self._api.external_interface_name, rpc.method_description))
         if self._generate_version_api:
             methods.extend(self._get_version_api_interface_method_signatures())
@@ -973,6 +1255,10 @@ class OpenApiArtGo(OpenApiArtPlugin):
         self._write(
             """
             {description}
+            //
+            // An Api is safe for concurrent use by multiple goroutines once its transport,
+            // authenticator and interceptors have been set up; Close must only be called
+            // after every operation in flight has returned.
             type Api interface {{
                 api
                 {method_signatures}
@@ -1031,13 +1317,6 @@ class OpenApiArtGo(OpenApiArtPlugin):
                     ),
                 )
 
-            # TODO: restore this in optimized way
-            # set_span_attrs = ""
-            # if rpc.struct is not None:
-            #     set_span_attrs = """api.Telemetry().SetSpanEvent(span, fmt.Sprintf("REQUEST: %s", {struct}.String()))""".format(
-            #         struct=rpc.struct
-            #     )
-
             info = rpc.status.get("information")
             status_type = rpc.status.get("status")
             status_str = ""
@@ -1063,7 +1342,7 @@ class OpenApiArtGo(OpenApiArtPlugin):
 
             if self._generate_version_api:
                 version_check = """
-                    if err := api.checkLocalRemoteVersionCompatibilityOnce(); err != nil {
+                    if err := api.checkLocalRemoteVersionCompatibilityOnce(ctx); err != nil {
                         return nil, err
                     }"""
             else:
@@ -1072,77 +1351,101 @@ class OpenApiArtGo(OpenApiArtPlugin):
             if rpc.streaming_type and rpc.streaming_type == "client":
                 marshal_str = """str, er := proto.Marshal({obj}.msg())
                 if er != nil {{
-                    return nil, er
+                    return er
                 }}""".format(
                     obj=rpc.struct,
                 )
-                stream_config = """var resp *{package}.{response}
-                var err error
-                if api.grpc.enableGrpcStreaming {{
+                stream_config = """if api.grpc.streamRequest(proto.Size(&request)) {{
                     {marshal}
                     resp, err = api.{operation}(ctx, {bts})
                 }} else {{
                 """.format(
-                    package=self._protobuf_package_name,
                     operation=rpc.stream_operation_name,
-                    response=rpc.streaming_response,
                     marshal="" if rpc.octet_bytes else marshal_str,
                     bts="data" if rpc.octet_bytes else "str",
                 )
+                streamed = ""
             elif rpc.streaming_type and rpc.streaming_type == "server":
-                stream_config = """var resp *{package}.{response}
-                var err error
-                if api.grpc.enableGrpcStreaming {{
-                    return api.{operation}(ctx, &request)
+                stream_config = """if api.grpc.enableGrpcStreaming {{
+                    streamed, err = api.{operation}(ctx, &request)
                 }} else {{
                 """.format(
                     operation=rpc.stream_operation_name,
-                    package=self._protobuf_package_name,
-                    response=rpc.streaming_response,
+                )
+                streamed = """var streamed {ret_type}
+                """.format(
+                    ret_type=rpc.request_return_type
                 )
             else:
                 stream_config = ""
+                streamed = ""
 
             self._write(
                 """func (api *{internal_struct_name}) {method} {{
+                    return api.{operation_name}Ctx(api.Telemetry().getRootContext(){args})
+                }}
+
+                func (api *{internal_struct_name}) {ctx_method} {{
                     {status}
                     {validate}
                     {log_request}
-                    {version_check}
-
-                    if api.hasHttpTransport() {{
+                    resp, err := api.invoke(ctx, "{operation_name}", {request_arg}, func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                        {invocation_request}
+                        {version_check}
+                        if api.hasHttpTransport() {{
                             {http_call}
+                        }}
+                        return api.grpc{operation_name}(ctx{args})
+                    }})
+                    if err != nil || resp == nil {{
+                        return nil, err
                     }}
+                    ret, ok := resp.({request_return_type})
+                    if !ok {{
+                        return nil, fmt.Errorf("{operation_name} received response of type %T instead of {request_return_type}", resp)
+                    }}
+                    return ret, nil
+                }}
 
-                    // adding spans grpc transport for OTLP instrumentation
-                    parentCtx := api.Telemetry().getRootContext()
-                    newCtx, span := api.Telemetry().NewSpan(parentCtx, "{operation_name}", trace.WithSpanKind(trace.SpanKindClient))
-                    defer api.Telemetry().CloseSpan(span)
-
+                func (api *{internal_struct_name}) {grpc_method} {{
                     if err := api.grpcConnect(); err != nil {{
                         return nil, err
                     }}
                     request := {request}
-                    if newCtx == nil {{
-                        newCtx = context.Background()
-                    }}
-                    ctx, cancelFunc := context.WithTimeout(newCtx, api.grpc.requestTimeout)
-                    defer cancelFunc()
-                    {stream_config_start}
-                    resp, err {declare}= api.grpcClient.{operation_name}(ctx, &request)
-                    {stream_config_end}
+                    var resp *{package}.{response}
+                    {streamed}
+                    err := api.grpc.retry(ctx, "{operation_name}", api.idempotent["{operation_name}"], func(ctx context.Context) error {{
+                        ctx, cancelFunc := api.grpc.requestContext(ctx)
+                        defer cancelFunc()
+                        ctx, err := api.grpcCredentialsContext(ctx, "{operation_name}")
+                        if err != nil {{
+                            return err
+                        }}
+                        {stream_config_start}
+                        resp, err = api.grpcClient.{operation_name}(ctx, &request, api.grpc.callOptions()...)
+                        {stream_config_end}
+                        return err
+                    }})
                     if err != nil {{
-                        api.Telemetry().SetSpanStatus(span, codes.Error, err.Error())
                         if er, ok := fromGrpcError(err); ok {{
                             return nil, er
                         }}
                         return nil, err
                     }}
+                    {streamed_return}
                     {return_value}
                 }}
                 """.format(
                     internal_struct_name=self._api.internal_struct_name,
                     method=rpc.method,
+                    ctx_method=rpc.ctx_method,
+                    grpc_method="grpc" + rpc.ctx_method.replace("Ctx(", "(", 1),
+                    args=", " + rpc.args if rpc.args else "",
+                    request_arg=rpc.args if rpc.args else "nil",
+                    invocation_request=self._invocation_request(
+                        rpc, rpc.operation_name
+                    ),
+                    request_return_type=rpc.request_return_type,
                     status=status_str,
                     request=rpc.request,
                     operation_name=rpc.operation_name,
@@ -1157,9 +1460,24 @@ class OpenApiArtGo(OpenApiArtPlugin):
                     else "",
                     stream_config_start=stream_config,
                     stream_config_end="}" if stream_config != "" else "",
-                    declare=":" if stream_config == "" else "",
+                    package=self._protobuf_package_name,
+                    response=rpc.streaming_response,
+                    streamed=streamed,
+                    streamed_return="""if api.grpc.enableGrpcStreaming {
+                        return streamed, nil
+                    }"""
+                    if streamed != ""
+                    else "",
                 )
             )
+            if rpc.reader_method is not None:
+                self._write(
+                    self._server_stream_reader_impl(
+                        self._api.internal_struct_name,
+                        rpc,
+                        "" if rpc.method == "GetVersion() (Version, error)" else version_check,
+                    )
+                )
 
         for http in self._api.internal_http_methods:
             error_handling = ""
@@ -1168,9 +1486,7 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 if response.status_code.startswith("2"):
                     success_method = response.request_return_type
                 else:
-                    error_handling += """err := fromHttpError(resp.StatusCode, bodyBytes)
-                    api.Telemetry().SetSpanStatus(span, codes.Error, err.Error())
-                    return nil, err"""
+                    error_handling += """return nil, fromHttpError(resp.StatusCode, bodyBytes)"""
 
             if http.request_return_type == "[]byte":
                 # logs.Debug("", "Response", string(bodyBytes))
@@ -1215,11 +1531,664 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 )
             )
 
+        self._write_security_definitions()
+        self._write_cassette_codecs()
+        self._write_wire_operations()
+        self._write_fake_api()
+        self._write_multi_api()
+
         if self._split_file:
             # we need to close the original gosnappi file for splitting it.
             # Rest of the interfaces will be created in different sub files.
             self._close_fp()
 
+    def _write_cassette_codecs(self):
+        """Writes the conversions of the requests and responses of every
+        operation to and from the JSON recorded in cassette files
+        """
+        codecs = []
+        for rpc in self._api.external_rpc_methods:
+            if rpc.request_type is None:
+                encode_request = "nil"
+            elif rpc.request_type == "[]byte":
+                encode_request = "encodeCassetteBytes"
+            else:
+                encode_request = """func(request interface{{}}) (json.RawMessage, error) {{
+                    return canonicalJson(request.({type}).Marshal().ToJson())
+                }}""".format(
+                    type=rpc.request_type
+                )
+            if rpc.request_return_type == "[]byte":
+                encode_response = "encodeCassetteBytes"
+                decode_response = "decodeCassetteBytes"
+            elif rpc.request_return_type == "*string":
+                encode_response = "encodeCassetteString"
+                decode_response = "decodeCassetteString"
+            else:
+                encode_response = """func(response interface{{}}) (json.RawMessage, error) {{
+                    return canonicalJson(response.({type}).Marshal().ToJson())
+                }}""".format(
+                    type=rpc.request_return_type
+                )
+                decode_response = """func(data json.RawMessage) (interface{{}}, error) {{
+                    response := New{type}()
+                    if err := response.Unmarshal().FromJson(string(data)); err != nil {{
+                        return nil, err
+                    }}
+                    return response, nil
+                }}""".format(
+                    type=rpc.request_return_type
+                )
+            codecs.append(
+                """"{operation}": {{
+                    encodeRequest:  {encode_request},
+                    encodeResponse: {encode_response},
+                    decodeResponse: {decode_response},
+                }},""".format(
+                    operation=rpc.operation_name,
+                    encode_request=encode_request,
+                    encode_response=encode_response,
+                    decode_response=decode_response,
+                )
+            )
+            if rpc.reader_method is not None:
+                codecs.append(
+                    """"{operation}Stream": {{
+                        encodeRequest:  {encode_request},
+                        encodeResponse: encodeCassetteBytes,
+                        decodeResponse: decodeCassetteReader,
+                    }},""".format(
+                        operation=rpc.operation_name,
+                        encode_request=encode_request,
+                    )
+                )
+        self._write(
+            """
+            // apiCassetteCodec converts the calls of the operations to and from the entries of cassette files
+            var apiCassetteCodec = cassetteCodec{{
+                operations: map[string]operationCodec{{
+                    {codecs}
+                }},
+                encodeError: cassetteError,
+                decodeError: fromCassetteError,
+            }}
+
+            // cassetteError returns the recorded form of an error returned by an operation
+            func cassetteError(err error) *CassetteError {{
+                cassetteErr := &CassetteError{{Message: err.Error()}}
+                if rErr, ok := err.(Error); ok {{
+                    cassetteErr.Error, _ = canonicalJson(rErr.Marshal().ToJson())
+                }}
+                return cassetteErr
+            }}
+
+            // fromCassetteError returns the error of a recorded call
+            func fromCassetteError(cassetteErr *CassetteError) error {{
+                if len(cassetteErr.Error) > 0 {{
+                    rErr := NewError()
+                    if err := rErr.Unmarshal().FromJson(string(cassetteErr.Error)); err == nil {{
+                        return rErr
+                    }}
+                }}
+                return errors.New(cassetteErr.Message)
+            }}
+            """.format(
+                codecs="\n".join(codecs)
+            )
+        )
+
+    def _write_wire_operations(self):
+        """Writes the encodings of the requests of every operation as the
+        grpc and the http transports send them, used by dry runs
+        """
+        operations = []
+        for rpc in self._api.external_rpc_methods:
+            if rpc.request_type is None:
+                encode_grpc = "nil"
+                encode_http = "nil"
+            elif rpc.request_type == "[]byte":
+                encode_grpc = """func(request interface{{}}) ([]byte, error) {{
+                    data := request.([]byte)
+                    return proto.Marshal(&{request})
+                }}""".format(
+                    request=rpc.request
+                )
+                encode_http = "encodeWireBytes"
+            else:
+                encode_grpc = """func(request interface{{}}) ([]byte, error) {{
+                    {struct} := request.({type})
+                    return proto.Marshal(&{request})
+                }}""".format(
+                    struct=rpc.args,
+                    type=rpc.request_type,
+                    request=rpc.request,
+                )
+                encode_http = """func(request interface{{}}) ([]byte, error) {{
+                    body, err := request.({type}).Marshal().ToJson()
+                    return []byte(body), err
+                }}""".format(
+                    type=rpc.request_type
+                )
+            if rpc.request_return_type == "[]byte":
+                empty_response = "[]byte{}"
+            elif rpc.request_return_type == "*string":
+                empty_response = "new(string)"
+            else:
+                empty_response = "New{type}()".format(
+                    type=rpc.request_return_type
+                )
+
+            def wire(grpc_method):
+                return """grpcMethod: "/" + {pb_pkg_name}.{proto_service}_ServiceDesc.ServiceName + "/{grpc_method}",
+                    httpMethod: "{http_method}",
+                    httpPath:   "{http_url}",
+                    encodeGrpc: {encode_grpc},
+                    encodeHttp: {encode_http},""".format(
+                    pb_pkg_name=self._protobuf_package_name,
+                    proto_service=self._proto_service,
+                    grpc_method=grpc_method,
+                    http_method=rpc.http_method,
+                    http_url=rpc.http_url,
+                    encode_grpc=encode_grpc,
+                    encode_http=encode_http,
+                )
+
+            operations.append(
+                """"{operation}": {{
+                    {wire}
+                    emptyResponse: func() interface{{}} {{
+                        return {empty_response}
+                    }},
+                }},""".format(
+                    operation=rpc.operation_name,
+                    wire=wire(rpc.operation_name),
+                    empty_response=empty_response,
+                )
+            )
+            if rpc.reader_method is not None:
+                operations.append(
+                    """"{operation}Stream": {{
+                        {wire}
+                        emptyResponse: emptyReader,
+                    }},""".format(
+                        operation=rpc.operation_name,
+                        wire=wire(rpc.stream_operation_name),
+                    )
+                )
+        self._write(
+            """
+            // apiWireOperations encodes the requests of the operations as the grpc and the http transports send them
+            var apiWireOperations = map[string]wireOperation{{
+                {operations}
+            }}
+            """.format(
+                operations="\n".join(operations)
+            )
+        )
+
+    def _write_fake_api(self):
+        """Writes FakeApi, an Api answering every operation with the
+        responses programmed by the test using it
+        """
+        methods = []
+        impls = []
+        for rpc in self._api.external_rpc_methods:
+            args = ", " + rpc.args if rpc.args else ""
+            request_arg = rpc.args if rpc.args else "nil"
+            info = rpc.status.get("information")
+            status_type = rpc.status.get("status")
+            status_str = ""
+            if status_type is not None:
+                status_str = self._get_status_msg(
+                    rpc.operation_name, status_type, info, "api"
+                )
+            methods.append(
+                """// {operation_name}Returns programs the response and the error returned by {operation_name}
+                {operation_name}Returns(response {request_return_type}, err error) FakeApi""".format(
+                    operation_name=rpc.operation_name,
+                    request_return_type=rpc.request_return_type,
+                )
+            )
+            impls.append(
+                """func (api *fakeApi) {method} {{
+                    return api.{operation_name}Ctx(api.Telemetry().getRootContext(){args})
+                }}
+
+                func (api *fakeApi) {ctx_method} {{
+                    {status}
+                    {validate}
+                    resp, err := api.invoke(ctx, "{operation_name}", {request_arg}, func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                        return api.call(ctx, "{operation_name}", request)
+                    }})
+                    if err != nil || resp == nil {{
+                        return nil, err
+                    }}
+                    ret, ok := resp.({request_return_type})
+                    if !ok {{
+                        return nil, fmt.Errorf("{operation_name} received response of type %T instead of {request_return_type}", resp)
+                    }}
+                    return ret, nil
+                }}
+
+                func (api *fakeApi) {operation_name}Returns(response {request_return_type}, err error) FakeApi {{
+                    return api.SetHandler("{operation_name}", func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                        return response, err
+                    }})
+                }}
+                """.format(
+                    method=rpc.method,
+                    ctx_method=rpc.ctx_method,
+                    operation_name=rpc.operation_name,
+                    request_return_type=rpc.request_return_type,
+                    args=args,
+                    request_arg=request_arg,
+                    status=status_str,
+                    validate=getattr(rpc, "validate", ""),
+                )
+            )
+            if rpc.reader_method is None:
+                continue
+            methods.append(
+                """// {operation_name}StreamReturns programs the data read from the reader and the error returned by {operation_name}Stream
+                {operation_name}StreamReturns(data []byte, err error) FakeApi""".format(
+                    operation_name=rpc.operation_name,
+                )
+            )
+            impls.append(
+                """func (api *fakeApi) {reader_method} {{
+                    {validate}
+                    resp, err := api.invoke(ctx, "{operation_name}Stream", {request_arg}, func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                        resp, err := api.call(ctx, "{operation_name}Stream", request)
+                        if err != nil || resp == nil {{
+                            return nil, err
+                        }}
+                        return fakeReader("{operation_name}Stream", resp)
+                    }})
+                    if err != nil || resp == nil {{
+                        return nil, err
+                    }}
+                    reader, ok := resp.(io.ReadCloser)
+                    if !ok {{
+                        return nil, fmt.Errorf("{operation_name}Stream received response of type %T instead of io.ReadCloser", resp)
+                    }}
+                    return reader, nil
+                }}
+
+                func (api *fakeApi) {operation_name}StreamReturns(data []byte, err error) FakeApi {{
+                    return api.SetHandler("{operation_name}Stream", func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                        if err != nil {{
+                            return nil, err
+                        }}
+                        return data, nil
+                    }})
+                }}
+                """.format(
+                    reader_method=rpc.reader_method,
+                    operation_name=rpc.operation_name,
+                    request_arg=request_arg,
+                    validate=getattr(rpc, "validate", ""),
+                )
+            )
+        if self._generate_version_api:
+            impls.append(
+                """// GetRemoteVersion returns the version programmed for GetVersion
+                func (api *fakeApi) GetRemoteVersion() (Version, error) {{
+                    return api.GetVersion()
+                }}
+
+                // CheckVersionCompatibility compares the local version with the version programmed for GetVersion
+                func (api *fakeApi) CheckVersionCompatibility() error {{
+                    remoteVersion, err := api.GetVersion()
+                    if err != nil {{
+                        return fmt.Errorf("version error: could not fetch remote version: %v", err)
+                    }}
+                    // the requests of a FakeApi are not sent to any location
+                    api.versionMeta.mutex.Lock()
+                    api.versionMeta.remoteVersions[""] = remoteVersion
+                    api.versionMeta.mutex.Unlock()
+                    return api.{internal_struct_name}.CheckVersionCompatibility()
+                }}
+                """.format(
+                    internal_struct_name=self._api.internal_struct_name
+                )
+            )
+        self._write(
+            """
+            // FakeApi is an Api for the unit tests of its users, no request is ever sent.
+            // Every operation returns the response programmed for it and is recorded along
+            // with its request; an operation without a programmed response returns an error.
+            // Requests are validated and interceptors run as they do for any other Api.
+            type FakeApi interface {{
+                Api
+                // SetHandler programs the responses of operation, e.g. SetConfig or GetCaptureStream
+                // for the reader of a streamed response, with a handler receiving its request
+                SetHandler(operation string, handler FakeHandler) FakeApi
+                // Calls returns the calls received so far in the order they have been made
+                Calls() []FakeCall
+                // CallsTo returns the calls of operation received so far in the order they have been made
+                CallsTo(operation string) []FakeCall
+                // VerifyCallOrder returns an error unless operations have been called in the given order,
+                // other calls may have been made before, after or in between them
+                VerifyCallOrder(operations ...string) error
+                // ResetCalls forgets the calls received so far, the programmed responses are kept
+                ResetCalls()
+                {methods}
+            }}
+
+            type fakeApi struct {{
+                *{internal_struct_name}
+                *fakeCalls
+            }}
+
+            // NewFakeApi returns a FakeApi without any programmed response
+            func NewFakeApi() FakeApi {{
+                api := &fakeApi{{{internal_struct_name}: newApi(), fakeCalls: newFakeCalls()}}
+                api.fake = true
+                return api
+            }}
+
+            func (api *fakeApi) SetHandler(operation string, handler FakeHandler) FakeApi {{
+                api.setHandler(operation, handler)
+                return api
+            }}
+
+            {impls}
+            """.format(
+                internal_struct_name=self._api.internal_struct_name,
+                methods="\n".join(methods),
+                impls="\n".join(impls),
+            )
+        )
+
+    def _write_multi_api(self):
+        """Writes MultiApi, which sends every request to many locations
+        concurrently through an Api per location
+        """
+        methods = []
+        impls = []
+        for rpc in self._api.external_rpc_methods:
+            args = ", " + rpc.args if rpc.args else ""
+            method = rpc.method[: rpc.method.index(")") + 1]
+            ctx_method = rpc.ctx_method[: rpc.ctx_method.index(")") + 1]
+            methods.append(
+                """// {operation_name} sends {operation_name} to every location
+                {method} (FanoutResults, error)
+                // {operation_name}Ctx is the same as {operation_name} but uses ctx for
+                // cancellation, deadlines and tracing of the calls
+                {ctx_method} (FanoutResults, error)""".format(
+                    operation_name=rpc.operation_name,
+                    method=method,
+                    ctx_method=ctx_method,
+                )
+            )
+            call_args = args
+            clone = ""
+            if rpc.args and rpc.request_type != "[]byte":
+                # validation is not safe for concurrent use of a request
+                call_args = ", requests[index]"
+                clone = """// every location is sent its own copy of the request
+                requests := make([]{request_type}, len(api.apis))
+                for i := range requests {{
+                    request, err := {struct}.Clone()
+                    if err != nil {{
+                        return nil, err
+                    }}
+                    requests[i] = request
+                }}""".format(
+                    request_type=rpc.request_type,
+                    struct=rpc.args,
+                )
+            impls.append(
+                """func (api *multiApi) {method} (FanoutResults, error) {{
+                    return api.{operation_name}Ctx(api.Telemetry().getRootContext(){args})
+                }}
+
+                func (api *multiApi) {ctx_method} (FanoutResults, error) {{
+                    {clone}
+                    return api.run(ctx, "{operation_name}", func(ctx context.Context, index int) (interface{{}}, error) {{
+                        return api.apis[index].{operation_name}Ctx(ctx{call_args})
+                    }})
+                }}
+                """.format(
+                    method=method,
+                    ctx_method=ctx_method,
+                    operation_name=rpc.operation_name,
+                    args=args,
+                    call_args=call_args,
+                    clone=clone,
+                )
+            )
+        self._write(
+            """
+            // MultiApi sends every request to each of its locations concurrently through an Api per location.
+            // A request returns its outcome for every location keyed by location, along with a *FanoutError
+            // when it has failed on some of them. Requests are sent to every location whatever the outcome
+            // on the others unless fail-fast is enabled.
+            type MultiApi interface {{
+                // Locations returns the locations the requests are sent to
+                Locations() []string
+                // Api returns the Api sending the requests to location, nil for an unknown location
+                Api(location string) Api
+                // SetMaxConcurrency sets the maximum number of locations a request is sent to at once, 16 by default
+                SetMaxConcurrency(value int) MultiApi
+                // MaxConcurrency returns the maximum number of locations a request is sent to at once
+                MaxConcurrency() int
+                // EnableFailFast stops sending a request once it has failed on a location,
+                // the requests in flight are cancelled and the others are not sent
+                EnableFailFast() MultiApi
+                // DisableFailFast sends a request to every location whatever the outcome on the others
+                DisableFailFast() MultiApi
+                // FailFast returns true when fail-fast is enabled
+                FailFast() bool
+                // Telemetry returns the telemetry recording a span per request summarizing its outcome
+                Telemetry() Telemetry
+                // SetCustomTelemetry sets the telemetry of the MultiApi and of the Api of every location
+                SetCustomTelemetry(telObj Telemetry)
+                // Do calls call with the Api of every location, what call returns is the outcome of its location;
+                // operation names the request in its error and telemetry
+                Do(ctx context.Context, operation string, call func(ctx context.Context, api Api) (interface{{}}, error)) (FanoutResults, error)
+                // Close closes the Api of every location
+                Close() error
+                {methods}
+            }}
+
+            type multiApi struct {{
+                *fanout
+                apis []Api
+            }}
+
+            // NewMultiApi returns a MultiApi sending the requests to locations, connect sets the transport
+            // of the Api of each location and a grpc transport to the location is used when it is nil
+            func NewMultiApi(locations []string, connect func(api Api, location string)) MultiApi {{
+                api := &multiApi{{fanout: newFanout(locations)}}
+                for _, location := range api.locations {{
+                    locationApi := NewApi()
+                    if connect != nil {{
+                        connect(locationApi, location)
+                    }} else {{
+                        locationApi.NewGrpcTransport().SetLocation(location)
+                    }}
+                    api.apis = append(api.apis, locationApi)
+                }}
+                return api
+            }}
+
+            func (api *multiApi) Locations() []string {{
+                return append([]string{{}}, api.locations...)
+            }}
+
+            func (api *multiApi) Api(location string) Api {{
+                for i, value := range api.locations {{
+                    if value == location {{
+                        return api.apis[i]
+                    }}
+                }}
+                return nil
+            }}
+
+            func (api *multiApi) SetMaxConcurrency(value int) MultiApi {{
+                api.maxConcurrency = value
+                return api
+            }}
+
+            func (api *multiApi) MaxConcurrency() int {{
+                return api.maxConcurrency
+            }}
+
+            func (api *multiApi) EnableFailFast() MultiApi {{
+                api.failFast = true
+                return api
+            }}
+
+            func (api *multiApi) DisableFailFast() MultiApi {{
+                api.failFast = false
+                return api
+            }}
+
+            func (api *multiApi) FailFast() bool {{
+                return api.failFast
+            }}
+
+            func (api *multiApi) Telemetry() Telemetry {{
+                return api.tracer
+            }}
+
+            func (api *multiApi) SetCustomTelemetry(telObj Telemetry) {{
+                api.tracer = telObj
+                for _, locationApi := range api.apis {{
+                    locationApi.SetCustomTelemetry(telObj)
+                }}
+            }}
+
+            func (api *multiApi) Do(ctx context.Context, operation string, call func(ctx context.Context, api Api) (interface{{}}, error)) (FanoutResults, error) {{
+                return api.run(ctx, operation, func(ctx context.Context, index int) (interface{{}}, error) {{
+                    return call(ctx, api.apis[index])
+                }})
+            }}
+
+            // Close closes the Api of every location and returns the first error
+            func (api *multiApi) Close() error {{
+                var err error
+                for _, locationApi := range api.apis {{
+                    if closeErr := locationApi.Close(); closeErr != nil && err == nil {{
+                        err = closeErr
+                    }}
+                }}
+                return err
+            }}
+
+            {impls}
+            """.format(
+                methods="\n".join(methods),
+                impls="\n".join(impls),
+            )
+        )
+
+    def _write_security_definitions(self):
+        """Writes the security requirements of each operation along with
+        an authenticator constructor for every security scheme in the spec
+        """
+        requirements = []
+        for rpc in self._api.external_rpc_methods:
+            if rpc.security is None:
+                continue
+            schemes = []
+            for requirement in rpc.security:
+                for scheme in requirement.keys():
+                    if scheme not in schemes:
+                        schemes.append(scheme)
+            requirements.append(
+                '"{}": {{{}}},'.format(
+                    rpc.operation_name,
+                    ", ".join(['"{}"'.format(s) for s in schemes]),
+                )
+            )
+        self._write(
+            """
+            // operationSecurity holds the security schemes accepted by each operation,
+            // an operation with no schemes does not require any credentials
+            var operationSecurity = map[string][]string{{
+                {requirements}
+            }}
+            """.format(
+                requirements="\n".join(requirements)
+            )
+        )
+
+        idempotent = []
+        for rpc in self._api.external_rpc_methods:
+            if rpc.http_method not in ["GET", "HEAD", "PUT", "DELETE", "OPTIONS"]:
+                continue
+            idempotent.append('"{}": true,'.format(rpc.operation_name))
+            if rpc.reader_method is not None:
+                idempotent.append(
+                    '"{}Stream": true,'.format(rpc.operation_name)
+                )
+        self._write(
+            """
+            // idempotentOperations holds the operations which are safe to retry
+            // as their http method is idempotent, along with their streaming calls
+            var idempotentOperations = map[string]bool{{
+                {idempotent}
+            }}
+            """.format(
+                idempotent="\n".join(idempotent)
+            )
+        )
+
+        schemes = self._openapi.get("components", {}).get(
+            "securitySchemes", {}
+        )
+        for name, scheme in schemes.items():
+            scheme_type = scheme.get("type")
+            http_scheme = str(scheme.get("scheme", "")).lower()
+            if scheme_type == "http" and http_scheme == "bearer":
+                params = "token string"
+                auth = "NewBearerAuthenticator(token)"
+                description = "sends the token in the Authorization header"
+            elif scheme_type == "http" and http_scheme == "basic":
+                params = "username string, password string"
+                auth = "NewBasicAuthenticator(username, password)"
+                description = "sends the username and password in the Authorization header"
+            elif scheme_type == "apiKey" and scheme.get("in") == "header":
+                params = "value string"
+                auth = 'NewApiKeyAuthenticator("{}", value)'.format(
+                    scheme["name"]
+                )
+                description = "sends the api key in the {} header".format(
+                    scheme["name"]
+                )
+            elif scheme_type == "apiKey" and scheme.get("in") == "cookie":
+                params = "value string"
+                auth = 'NewApiKeyAuthenticator("Cookie", "{}="+value)'.format(
+                    scheme["name"]
+                )
+                description = "sends the api key in the {} cookie".format(
+                    scheme["name"]
+                )
+            elif scheme_type in ["oauth2", "openIdConnect"]:
+                params = "source TokenSource"
+                auth = "NewTokenAuthenticator(source)"
+                description = "sends the token obtained from source in the Authorization header"
+            else:
+                # api keys sent as query parameters and other schemes are not supported
+                continue
+            self._write(
+                """
+                // New{struct}Authenticator returns an Authenticator for the {name} security scheme,
+                // it {description} of the operations accepting this scheme
+                func New{struct}Authenticator({params}) Authenticator {{
+                    return &schemeAuthenticator{{Authenticator: {auth}, scheme: "{name}"}}
+                }}
+                """.format(
+                    struct=self._get_external_struct_name(name),
+                    name=name,
+                    description=description,
+                    params=params,
+                    auth=auth,
+                )
+            )
+
     def _build_request_interfaces(self):
         for new in self._api.external_new_methods:
             self._write_interface(new)
@@ -1243,6 +2212,8 @@ class OpenApiArtGo(OpenApiArtPlugin):
     def _get_version_api_interface_method_impl(self, struct_name):
         return """
             func (api *{0}) GetLocalVersion() Version {{
+                api.versionMeta.mutex.Lock()
+                defer api.versionMeta.mutex.Unlock()
                 if api.versionMeta.localVersion == nil {{
                     api.versionMeta.localVersion = NewVersion().SetApiSpecVersion("{1}").SetSdkVersion("{2}")
                 }}
@@ -1251,40 +2222,67 @@ class OpenApiArtGo(OpenApiArtPlugin):
             }}
 
             func (api *{0}) GetRemoteVersion() (Version, error) {{
-                if api.versionMeta.remoteVersion == nil {{
-                    v, err := api.GetVersion()
-                    if err != nil {{
-                        return nil, fmt.Errorf("could not fetch remote version: %v", err)
-                    }}
+                return api.getRemoteVersion(api.Telemetry().getRootContext())
+            }}
+
+            // getRemoteVersion returns the version of the location the request of ctx is sent to,
+            // which is the active location of the transport outside of a request
+            func (api *{0}) getRemoteVersion(ctx context.Context) (Version, error) {{
+                ctx, location := api.locationContext(ctx)
+                api.versionMeta.mutex.Lock()
+                remoteVersion := api.versionMeta.remoteVersions[location]
+                api.versionMeta.mutex.Unlock()
+                if remoteVersion != nil {{
+                    return remoteVersion, nil
+                }}
 
-                    api.versionMeta.remoteVersion = v
+                // the lock is not held while fetching as GetVersion goes through the
+                // transport, concurrent callers may fetch but only the first one is kept
+                v, err := api.GetVersionCtx(ctx)
+                if err != nil {{
+                    // wrapped so that a location which cannot be reached is failed over
+                    return nil, fmt.Errorf("could not fetch remote version: %w", err)
                 }}
 
-                return api.versionMeta.remoteVersion, nil
+                api.versionMeta.mutex.Lock()
+                defer api.versionMeta.mutex.Unlock()
+                if _, ok := api.versionMeta.remoteVersions[location]; !ok {{
+                    api.versionMeta.remoteVersions[location] = v
+                }}
+                return api.versionMeta.remoteVersions[location], nil
             }}
 
             func (api *{0}) SetVersionCompatibilityCheck(v bool) {{
+                api.versionMeta.mutex.Lock()
+                defer api.versionMeta.mutex.Unlock()
                 api.versionMeta.checkVersion = v
+                // the local version may have changed since the locations have been checked
+                api.versionMeta.checked = map[string]bool{{}}
             }}
 
             func (api *{0}) SetComponentInformation(clientName string, clientVer string, serverName string) {{
+                api.versionMeta.mutex.Lock()
+                defer api.versionMeta.mutex.Unlock()
                 api.versionMeta.clientName = clientName
                 api.versionMeta.clientAppVer = clientVer
                 api.versionMeta.serverName = serverName
             }}
 
-            func (api *{0}) checkLocalRemoteVersionCompatibility() (error, error) {{
+            func (api *{0}) checkLocalRemoteVersionCompatibility(ctx context.Context) (error, error) {{
                 localVer := api.GetLocalVersion()
-                remoteVer, err := api.GetRemoteVersion()
+                remoteVer, err := api.getRemoteVersion(ctx)
                 if err != nil {{
                     return nil, err
                 }}
                 err = checkClientServerVersionCompatibility(localVer.ApiSpecVersion(), remoteVer.ApiSpecVersion(), "API spec")
                 if err != nil {{
-                    if api.versionMeta.clientName != "" {{
+                    api.versionMeta.mutex.Lock()
+                    clientName, clientAppVer, serverName := api.versionMeta.clientName, api.versionMeta.clientAppVer, api.versionMeta.serverName
+                    api.versionMeta.mutex.Unlock()
+                    if clientName != "" {{
                         return fmt.Errorf(
-                        "%s %s is not compatible with %s %s", api.versionMeta.clientName, api.versionMeta.clientAppVer,
-                        api.versionMeta.serverName, remoteVer.AppVersion(),), nil
+                        "%s %s is not compatible with %s %s", clientName, clientAppVer,
+                        serverName, remoteVer.AppVersion(),), nil
                     }} else {{
                         return fmt.Errorf(
                         "client SDK version '%s' is not compatible with server SDK version '%s': %v",
@@ -1296,32 +2294,41 @@ class OpenApiArtGo(OpenApiArtPlugin):
                 return nil, nil
             }}
 
-            func (api *{0}) checkLocalRemoteVersionCompatibilityOnce() error {{
-                if !api.versionMeta.checkVersion {{
+            // checkLocalRemoteVersionCompatibilityOnce checks the version of every location
+            // the first time a request is sent to it
+            func (api *{0}) checkLocalRemoteVersionCompatibilityOnce(ctx context.Context) error {{
+                ctx, location := api.locationContext(ctx)
+                api.versionMeta.mutex.Lock()
+                checkVersion, checked := api.versionMeta.checkVersion, api.versionMeta.checked[location]
+                checkError := api.versionMeta.checkErrors[location]
+                api.versionMeta.mutex.Unlock()
+                if !checkVersion || checked {{
                     return nil
                 }}
 
-                if api.versionMeta.checkError != nil {{
-                    return api.versionMeta.checkError
+                if checkError != nil {{
+                    return checkError
                 }}
 
-                compatErr, apiErr := api.checkLocalRemoteVersionCompatibility()
+                compatErr, apiErr := api.checkLocalRemoteVersionCompatibility(ctx)
+                api.versionMeta.mutex.Lock()
+                defer api.versionMeta.mutex.Unlock()
                 if compatErr != nil {{
-                    api.versionMeta.checkError = compatErr
+                    api.versionMeta.checkErrors[location] = compatErr
                     return compatErr
                 }}
                 if apiErr != nil {{
-                    api.versionMeta.checkError = nil
+                    delete(api.versionMeta.checkErrors, location)
                     return apiErr
                 }}
 
-                api.versionMeta.checkVersion = false
-                api.versionMeta.checkError = nil
+                api.versionMeta.checked[location] = true
+                delete(api.versionMeta.checkErrors, location)
                 return nil
             }}
 
             func (api *{0}) CheckVersionCompatibility() error {{
-                compatErr, apiErr := api.checkLocalRemoteVersionCompatibility()
+                compatErr, apiErr := api.checkLocalRemoteVersionCompatibility(api.Telemetry().getRootContext())
                 if compatErr != nil {{
                     return fmt.Errorf("version error: %v", compatErr)
                 }}
@@ -1338,14 +2345,44 @@ class OpenApiArtGo(OpenApiArtPlugin):
     def _client_stream_method_impl(self, struct_name, rpc_info):
         return """
         func (api *{struct}) {operation}(ctx context.Context, data []byte) (*{pkg}.{response}, error) {{
-            chunkSize := api.grpc.chunkSize
-            streamClient, err := api.grpcClient.{pkg_op}(ctx)
+            if api.grpc.uploadRestarts <= 0 {{
+                return api.send{pkg_op}(ctx, data, "")
+            }}
+            var res *{pkg}.{response}
+            err := api.grpc.resumeUpload(ctx, "{operation_name}", func(ctx context.Context, uploadId string) error {{
+                var err error
+                res, err = api.send{pkg_op}(ctx, data, uploadId)
+                return err
+            }})
+            return res, err
+        }}
+
+        // send{pkg_op} uploads data in chunks, a resumable upload identified by uploadId
+        // starts from the offset acknowledged by the server
+        func (api *{struct}) send{pkg_op}(ctx context.Context, data []byte, uploadId string) (*{pkg}.{response}, error) {{
+            ctx, cancelFunc := context.WithCancel(ctx)
+            defer cancelFunc()
+            if uploadId != "" {{
+                ctx = metadata.AppendToOutgoingContext(ctx, UploadIdMetadataKey, uploadId)
+            }}
+            chunkSize := api.grpc.streamChunkSize()
+            streamClient, err := api.grpcClient.{pkg_op}(ctx, api.grpc.callOptions()...)
             if err != nil {{
                 return nil, err
             }}
             bytes := []byte(data)
             var i uint64
-            for i = 0; i < uint64(len(bytes)); i += chunkSize {{
+            if uploadId != "" {{
+                header, err := streamClient.Header()
+                if err != nil {{
+                    return nil, err
+                }}
+                if i, err = uploadOffset(header.Get(UploadOffsetMetadataKey), len(bytes)); err != nil {{
+                    return nil, err
+                }}
+            }}
+            chunk := int(i / chunkSize)
+            for ; i < uint64(len(bytes)); i += chunkSize {{
                 data := &{pkg}.Data{{}}
                 data.ChunkSize = uint64(chunkSize)
                 if i+chunkSize > uint64(len(bytes)) {{
@@ -1354,8 +2391,21 @@ class OpenApiArtGo(OpenApiArtPlugin):
                     data.Datum = bytes[i : i+chunkSize]
                 }}
                 if err := streamClient.Send(data); err != nil {{
+                    if err == io.EOF {{
+                        // the server has ended the stream, its status tells why
+                        _, err = streamClient.CloseAndRecv()
+                    }}
                     return nil, err
                 }}
+                if api.grpc.uploadProgress != nil {{
+                    api.grpc.uploadProgress(UploadProgress{{
+                        Operation: "{operation_name}",
+                        Sent:      i + uint64(len(data.Datum)),
+                        Total:     uint64(len(bytes)),
+                        Chunk:     chunk,
+                    }})
+                }}
+                chunk++
             }}
             res, err := streamClient.CloseAndRecv()
             if err != nil {{
@@ -1367,6 +2417,7 @@ class OpenApiArtGo(OpenApiArtPlugin):
             struct=struct_name,
             pkg=self._protobuf_package_name,
             operation=rpc_info.stream_operation_name,
+            operation_name=rpc_info.operation_name,
             pkg_op=rpc_info.stream_operation_name[0].upper()
             + rpc_info.stream_operation_name[1:],
             response=rpc_info.streaming_response,
@@ -1390,22 +2441,33 @@ class OpenApiArtGo(OpenApiArtPlugin):
             )
 
         return """
-        func (api *{struct}) {operation}(ctx context.Context, req {request}) ({response}, error) {{
-            streamClient, err := api.grpcClient.{pkg_op}(ctx, req)
+        func (api *{struct}) open{pkg_op}(ctx context.Context, req {request}) (*streamReader, error) {{
+            ctx, cancelFunc := context.WithCancel(ctx)
+            streamClient, err := api.grpcClient.{pkg_op}(ctx, req, api.grpc.callOptions()...)
             if err != nil {{
+                cancelFunc()
                 return nil, err
             }}
-            var bytes []byte
-            for {{
+            return newStreamReader(func() ([]byte, error) {{
                 resp, err := streamClient.Recv()
-                if err == io.EOF {{
-                    {return_value}
-                }}
                 if err != nil {{
                     return nil, err
                 }}
-                bytes = append(bytes, resp.Datum...)
+                return resp.Datum, nil
+            }}, cancelFunc), nil
+        }}
+
+        func (api *{struct}) {operation}(ctx context.Context, req {request}) ({response}, error) {{
+            reader, err := api.open{pkg_op}(ctx, req)
+            if err != nil {{
+                return nil, err
             }}
+            defer reader.Close()
+            bytes, err := io.ReadAll(reader)
+            if err != nil {{
+                return nil, err
+            }}
+            {return_value}
         }}
         """.format(
             struct=struct_name,
@@ -1423,6 +2485,101 @@ class OpenApiArtGo(OpenApiArtPlugin):
             return_value=ret,
         )
 
+    def _invocation_request(self, rpc, operation_name):
+        """Returns the statements taking the request argument of an operation
+        from the request of the invocation which interceptors may have replaced"""
+        if not rpc.args:
+            return ""
+        return """{arg}, ok := request.({request_type})
+            if !ok {{
+                return nil, fmt.Errorf("{operation_name} received request of type %T instead of {request_type}", request)
+            }}""".format(
+            arg=rpc.args,
+            request_type=rpc.request_type,
+            operation_name=operation_name,
+        )
+
+    def _server_stream_reader_impl(self, struct_name, rpc, version_check):
+        args = ", " + rpc.args if rpc.args else ""
+        return """
+        func (api *{struct}) {reader_method} {{
+            {validate}
+            resp, err := api.invoke(ctx, "{operation_name}Stream", {request_arg}, func(ctx context.Context, request interface{{}}) (interface{{}}, error) {{
+                {invocation_request}
+                {version_check}
+                if api.hasHttpTransport() {{
+                    return api.http{operation_name}Stream(ctx{args})
+                }}
+                return api.grpc{operation_name}Stream(ctx{args})
+            }})
+            if err != nil || resp == nil {{
+                return nil, err
+            }}
+            reader, ok := resp.(io.ReadCloser)
+            if !ok {{
+                return nil, fmt.Errorf("{operation_name}Stream received response of type %T instead of io.ReadCloser", resp)
+            }}
+            return reader, nil
+        }}
+
+        func (api *{struct}) grpc{grpc_method} {{
+            if err := api.grpcConnect(); err != nil {{
+                return nil, err
+            }}
+            request := {request}
+            ctx, err := api.grpcCredentialsContext(ctx, "{operation_name}")
+            if err != nil {{
+                return nil, err
+            }}
+            reader, err := api.open{stream_op}(ctx, &request)
+            if err != nil {{
+                if er, ok := fromGrpcError(err); ok {{
+                    return nil, er
+                }}
+                return nil, err
+            }}
+            reader.mapError = func(err error) error {{
+                if er, ok := fromGrpcError(err); ok {{
+                    return er
+                }}
+                return err
+            }}
+            return reader, nil
+        }}
+
+        func (api *{struct}) http{grpc_method} {{
+            {http_request}
+            if err != nil {{
+                return nil, err
+            }}
+            if resp.StatusCode != 200 {{
+                defer resp.Body.Close()
+                bodyBytes, err := io.ReadAll(resp.Body)
+                if err != nil {{
+                    return nil, err
+                }}
+                return nil, fromHttpError(resp.StatusCode, bodyBytes)
+            }}
+            return resp.Body, nil
+        }}
+        """.format(
+            struct=struct_name,
+            reader_method=rpc.reader_method,
+            grpc_method=rpc.reader_method,
+            validate=getattr(rpc, "validate", ""),
+            operation_name=rpc.operation_name,
+            request_arg=rpc.args if rpc.args else "nil",
+            invocation_request=self._invocation_request(
+                rpc, rpc.operation_name + "Stream"
+            ),
+            version_check=version_check,
+            args=args,
+            request=rpc.request,
+            stream_op=rpc.stream_operation_name[0].upper()
+            + rpc.stream_operation_name[1:],
+            http_request=rpc.http_request,
+        )
+
     def _write_component_interfaces(self):
         while True:
             components = [
@@ -1,17 +1,35 @@
 import (
 	"fmt"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
@@ -22,17 +40,116 @@ type telemetry struct {
 	rootCtx       context.Context
 	spanProcessor sdktrace.SpanProcessor
 	traceProvider *sdktrace.TracerProvider
+	tlsConfig     *tls.Config
+	headers       map[string]string
+	timeout       time.Duration
+	compression   string
+	urlPath       string
+	filePath      string
+	memory        *memorySpanExporter
+	samplingRatio *float64
+	parentBased   bool
+	meterProvider metric.MeterProvider
+	// otlpMeterProvider exports the metrics to the collector when no meter provider is set
+	otlpMeterProvider *sdkmetric.MeterProvider
+	metrics           *clientMetrics
+	payloadCapture    bool
+	payloadByteBudget int
+	payloadRatio      *float64
+	redactedFields    []string
 }
 
+// the payloads captured on the spans are truncated to 4096 bytes by default
+const defaultPayloadByteBudget = 4096
+
+// defaultRedactedFields are the fields whose values are redacted from the captured payloads by default
+var defaultRedactedFields = []string{"password", "secret", "token", "api_key", "private_key", "authorization"}
+
+// redactedValue replaces the value of a redacted field in the captured payloads
+const redactedValue = "[REDACTED]"
+
 type Telemetry interface {
 	isOTLPEnabled() bool
 	getRootContext() context.Context
+	clientMetrics() *clientMetrics
+	capturesPayloads(span trace.Span) bool
+	addPayloadEvent(span trace.Span, name string, payload []byte)
 	SetHTTP() Telemetry
 	SetGRPC() Telemetry
 	SetOtelCollector(endpoint string) Telemetry
 	SetRootContext(ctx context.Context) Telemetry
 	SetCustomSpanProcess(spanProcessor sdktrace.SpanProcessor) Telemetry
 	SetServiceName(serviceName string) Telemetry
+	// SetTLSConfig secures the connection to the collector, the connection is insecure when it is nil
+	SetTLSConfig(value *tls.Config) Telemetry
+	// TLSConfig returns the tls configuration of the connection to the collector
+	TLSConfig() *tls.Config
+	// SetHeaders sets the http headers or the grpc metadata sent along with every export,
+	// e.g. the credentials expected by the collector
+	SetHeaders(value map[string]string) Telemetry
+	// Headers returns the http headers or the grpc metadata sent along with every export
+	Headers() map[string]string
+	// SetExporterTimeout sets the timeout of every export and of connecting to the collector over grpc,
+	// the timeout of an export is 10 seconds and of connecting one second when it is not set
+	SetExporterTimeout(value time.Duration) Telemetry
+	// ExporterTimeout returns the timeout of every export
+	ExporterTimeout() time.Duration
+	// SetExporterCompression compresses the exported spans, CompressionGzip or CompressionNone which is the default
+	SetExporterCompression(value string) Telemetry
+	// ExporterCompression returns the compression of the exported spans
+	ExporterCompression() string
+	// SetURLPath sets the url path the spans are exported to over http, /v1/traces by default
+	SetURLPath(value string) Telemetry
+	// URLPath returns the url path the spans are exported to over http
+	URLPath() string
+	// SetStdoutExporter writes the spans to stdout as json, one span per line, instead of exporting them to a collector
+	SetStdoutExporter() Telemetry
+	// SetFileExporter writes the spans as json to the file at path, one span per line,
+	// instead of exporting them to a collector, the file is truncated on Start
+	SetFileExporter(path string) Telemetry
+	// SetInMemoryExporter keeps the spans in memory instead of exporting them to a collector,
+	// the spans are returned by Spans as soon as they end and until they are reset, even once telemetry is stopped
+	SetInMemoryExporter() Telemetry
+	// Spans returns the spans kept by the in-memory exporter, nil for any other exporter
+	Spans() SpanSnapshots
+	// ResetSpans forgets the spans kept by the in-memory exporter
+	ResetSpans()
+	// SetSamplingRatio samples the given fraction of the traces, between 0 and 1, every trace is sampled by default
+	SetSamplingRatio(value float64) Telemetry
+	// SamplingRatio returns the fraction of the traces sampled
+	SamplingRatio() float64
+	// EnableParentBasedSampling samples a span along with its parent, the sampling ratio only applies to root spans
+	EnableParentBasedSampling() Telemetry
+	// DisableParentBasedSampling applies the sampling ratio to every span regardless of its parent, which is the default
+	DisableParentBasedSampling() Telemetry
+	// ParentBasedSampling returns true if a span is sampled along with its parent
+	ParentBasedSampling() bool
+	// SetMeterProvider records the metrics of the calls of the operations with value from now on,
+	// instead of exporting them to the collector once telemetry is started
+	SetMeterProvider(value metric.MeterProvider) Telemetry
+	// MeterProvider returns the meter provider set to record the metrics of the calls of the operations
+	MeterProvider() metric.MeterProvider
+	// EnablePayloadCapture adds the JSON of the request and of the response of every call as the events
+	// REQUEST and RESPONSE of its span, the payloads of the streams are not captured
+	EnablePayloadCapture() Telemetry
+	// DisablePayloadCapture stops capturing the payloads of the calls, which is the default
+	DisablePayloadCapture() Telemetry
+	// PayloadCapture returns true if the payloads of the calls are captured
+	PayloadCapture() bool
+	// SetPayloadByteBudget truncates every captured payload to value bytes, 4096 by default
+	SetPayloadByteBudget(value int) Telemetry
+	// PayloadByteBudget returns the number of bytes every captured payload is truncated to
+	PayloadByteBudget() int
+	// SetPayloadSamplingRatio captures the payloads of the given fraction of the sampled calls, between 0 and 1,
+	// the payloads of every sampled call are captured by default
+	SetPayloadSamplingRatio(value float64) Telemetry
+	// PayloadSamplingRatio returns the fraction of the sampled calls whose payloads are captured
+	PayloadSamplingRatio() float64
+	// SetRedactedFields sets the names of the fields, at any depth, whose values are redacted from the captured payloads,
+	// the names are case insensitive and are password, secret, token, api_key, private_key and authorization by default
+	SetRedactedFields(value []string) Telemetry
+	// RedactedFields returns the names of the fields whose values are redacted from the captured payloads
+	RedactedFields() []string
 	Start() (Telemetry, error)
 	Stop()
 	NewSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span)
@@ -46,8 +163,20 @@ var tracer trace.Tracer
 
 // Internal fucntion to check wheather telemetry is enabled or not.
 // Used by rest of the functions to become no-ops.
+// Telemetry is enabled once it is started with an exporter and until it is stopped.
 func (t *telemetry) isOTLPEnabled() bool {
-	return t.endpoint != ""
+	return t.traceProvider != nil
+}
+
+// Internal function to check wheather an exporter is configured, spans are
+// exported to a collector only when its endpoint is set
+func (t *telemetry) hasExporter() bool {
+	switch t.transport {
+	case "STDOUT", "FILE", "MEMORY":
+		return true
+	default:
+		return t.endpoint != ""
+	}
 }
 
 // Internal function to fetch the root context if provided by user.
@@ -98,13 +227,400 @@ func (t *telemetry) SetServiceName(serviceName string) Telemetry {
 	return t
 }
 
+// Secures the connection to the collector using tls
+func (t *telemetry) SetTLSConfig(value *tls.Config) Telemetry {
+	t.tlsConfig = value
+	return t
+}
+
+func (t *telemetry) TLSConfig() *tls.Config {
+	return t.tlsConfig
+}
+
+// Writes the spans to stdout as json
+func (t *telemetry) SetStdoutExporter() Telemetry {
+	t.transport = "STDOUT"
+	return t
+}
+
+// Writes the spans as json to the file at path
+func (t *telemetry) SetFileExporter(path string) Telemetry {
+	t.transport = "FILE"
+	t.filePath = path
+	return t
+}
+
+// Keeps the spans in memory, mostly useful to inspect the spans in tests
+func (t *telemetry) SetInMemoryExporter() Telemetry {
+	t.transport = "MEMORY"
+	if t.memory == nil {
+		t.memory = &memorySpanExporter{}
+	}
+	return t
+}
+
+func (t *telemetry) Spans() SpanSnapshots {
+	if t.transport != "MEMORY" || t.memory == nil {
+		return nil
+	}
+	return t.memory.spans()
+}
+
+func (t *telemetry) ResetSpans() {
+	if t.memory != nil {
+		t.memory.reset()
+	}
+}
+
+// Sets the fraction of the traces sampled, values out of range are not considered
+func (t *telemetry) SetSamplingRatio(value float64) Telemetry {
+	if value < 0 || value > 1 {
+		fmt.Printf("The sampling ratio %v is not between 0 and 1, so will not be considered\n", value)
+		return t
+	}
+	t.samplingRatio = &value
+	return t
+}
+
+func (t *telemetry) SamplingRatio() float64 {
+	if t.samplingRatio == nil {
+		return 1
+	}
+	return *t.samplingRatio
+}
+
+func (t *telemetry) EnableParentBasedSampling() Telemetry {
+	t.parentBased = true
+	return t
+}
+
+func (t *telemetry) DisableParentBasedSampling() Telemetry {
+	t.parentBased = false
+	return t
+}
+
+func (t *telemetry) ParentBasedSampling() bool {
+	return t.parentBased
+}
+
+// Records the metrics with the meter provider set by the user,
+// metrics are no longer recorded when it is nil
+func (t *telemetry) SetMeterProvider(value metric.MeterProvider) Telemetry {
+	t.meterProvider = value
+	t.metrics = nil
+	if value != nil {
+		metrics, err := newClientMetrics(value)
+		if err != nil {
+			logs.Error("Failed creating the metrics of the operations", "error", err)
+			return t
+		}
+		t.metrics = metrics
+	}
+	return t
+}
+
+func (t *telemetry) MeterProvider() metric.MeterProvider {
+	return t.meterProvider
+}
+
+// Internal function to fetch the metrics of the calls of the operations,
+// nil when they are not recorded
+func (t *telemetry) clientMetrics() *clientMetrics {
+	return t.metrics
+}
+
+func (t *telemetry) EnablePayloadCapture() Telemetry {
+	t.payloadCapture = true
+	return t
+}
+
+func (t *telemetry) DisablePayloadCapture() Telemetry {
+	t.payloadCapture = false
+	return t
+}
+
+func (t *telemetry) PayloadCapture() bool {
+	return t.payloadCapture
+}
+
+// Sets the number of bytes every captured payload is truncated to, values which are not positive are not considered
+func (t *telemetry) SetPayloadByteBudget(value int) Telemetry {
+	if value <= 0 {
+		fmt.Printf("The payload byte budget %d is not positive, so will not be considered\n", value)
+		return t
+	}
+	t.payloadByteBudget = value
+	return t
+}
+
+func (t *telemetry) PayloadByteBudget() int {
+	if t.payloadByteBudget == 0 {
+		return defaultPayloadByteBudget
+	}
+	return t.payloadByteBudget
+}
+
+// Sets the fraction of the sampled calls whose payloads are captured, values out of range are not considered
+func (t *telemetry) SetPayloadSamplingRatio(value float64) Telemetry {
+	if value < 0 || value > 1 {
+		fmt.Printf("The payload sampling ratio %v is not between 0 and 1, so will not be considered\n", value)
+		return t
+	}
+	t.payloadRatio = &value
+	return t
+}
+
+func (t *telemetry) PayloadSamplingRatio() float64 {
+	if t.payloadRatio == nil {
+		return 1
+	}
+	return *t.payloadRatio
+}
+
+func (t *telemetry) SetRedactedFields(value []string) Telemetry {
+	t.redactedFields = value
+	return t
+}
+
+func (t *telemetry) RedactedFields() []string {
+	if t.redactedFields == nil {
+		return defaultRedactedFields
+	}
+	return t.redactedFields
+}
+
+// Internal function to check wheather the payloads of the call traced by span are captured
+func (t *telemetry) capturesPayloads(span trace.Span) bool {
+	if !t.payloadCapture || !t.isOTLPEnabled() || span == nil || !span.IsRecording() {
+		return false
+	}
+	ratio := t.PayloadSamplingRatio()
+	return ratio >= 1 || rand.Float64() < ratio
+}
+
+// Internal function adding the event name to span along with the JSON payload,
+// whose sensitive fields are redacted and which is truncated to the byte budget
+func (t *telemetry) addPayloadEvent(span trace.Span, name string, payload []byte) {
+	payload = redactPayload(payload, t.RedactedFields())
+	size := len(payload)
+	truncated := size > t.PayloadByteBudget()
+	if truncated {
+		// the payload is not cut within a character
+		end := t.PayloadByteBudget()
+		for end > 0 && !utf8.RuneStart(payload[end]) {
+			end--
+		}
+		payload = payload[:end]
+	}
+	span.AddEvent(name, trace.WithAttributes(
+		attribute.String("payload", string(payload)),
+		attribute.Int("payload.size", size),
+		attribute.Bool("payload.truncated", truncated),
+	))
+}
+
+// redactPayload returns the JSON payload with the values of the fields named fields redacted
+func redactPayload(payload []byte, fields []string) []byte {
+	if len(fields) == 0 {
+		return payload
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	// the numbers are kept as they are instead of being converted to floats
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || !redactValue(decoded, fields) {
+		return payload
+	}
+	redacted, err := json.Marshal(decoded)
+	if err != nil {
+		return payload
+	}
+	return redacted
+}
+
+// redactValue redacts the values of the fields named fields within value, it returns true if any has been redacted
+func redactValue(value interface{}, fields []string) bool {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedField(key, fields) {
+				v[key] = redactedValue
+				redacted = true
+			} else if redactValue(field, fields) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item, fields) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func redactedField(name string, fields []string) bool {
+	for _, field := range fields {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampler returns the sampler of the trace provider according to the sampling ratio
+// and wheather the sampling is parent based
+func (t *telemetry) sampler() sdktrace.Sampler {
+	sampler := sdktrace.AlwaysSample()
+	if ratio := t.SamplingRatio(); ratio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(ratio)
+	}
+	if t.parentBased {
+		return sdktrace.ParentBased(sampler)
+	}
+	return sampler
+}
+
+// Sets the headers sent to the collector along with every export
+func (t *telemetry) SetHeaders(value map[string]string) Telemetry {
+	t.headers = value
+	return t
+}
+
+func (t *telemetry) Headers() map[string]string {
+	return t.headers
+}
+
+// Sets the timeout of every export and of connecting to the collector over grpc
+func (t *telemetry) SetExporterTimeout(value time.Duration) Telemetry {
+	t.timeout = value
+	return t
+}
+
+func (t *telemetry) ExporterTimeout() time.Duration {
+	return t.timeout
+}
+
+// Sets the compression of the exported spans, only gzip is supported by the collectors
+func (t *telemetry) SetExporterCompression(value string) Telemetry {
+	if value != CompressionNone && value != CompressionGzip {
+		fmt.Printf("The compression %s is not supported, so will not be considered. supported values are %s and %s\n", value, CompressionNone, CompressionGzip)
+		return t
+	}
+	t.compression = value
+	return t
+}
+
+func (t *telemetry) ExporterCompression() string {
+	if t.compression == "" {
+		return CompressionNone
+	}
+	return t.compression
+}
+
+// Sets the url path the spans are exported to over http
+func (t *telemetry) SetURLPath(value string) Telemetry {
+	t.urlPath = value
+	return t
+}
+
+func (t *telemetry) URLPath() string {
+	return t.urlPath
+}
+
+// httpClientOptions returns the options of the client exporting the spans over http
+func (t *telemetry) httpClientOptions() []otlptracehttp.Option {
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(t.endpoint)}
+	if t.tlsConfig != nil {
+		options = append(options, otlptracehttp.WithTLSClientConfig(t.tlsConfig))
+	} else {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if len(t.headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(t.headers))
+	}
+	if t.timeout > 0 {
+		options = append(options, otlptracehttp.WithTimeout(t.timeout))
+	}
+	if t.compression == CompressionGzip {
+		options = append(options, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if t.urlPath != "" {
+		options = append(options, otlptracehttp.WithURLPath(t.urlPath))
+	}
+	return options
+}
+
+// grpcDialOptions returns the options of the connection to the collector over grpc,
+// the exporter ignores its own connection options when it is given a connection
+func (t *telemetry) grpcDialOptions() []grpc.DialOption {
+	transportCredentials := insecure.NewCredentials()
+	if t.tlsConfig != nil {
+		transportCredentials = credentials.NewTLS(t.tlsConfig)
+	}
+	options := []grpc.DialOption{grpc.WithTransportCredentials(transportCredentials), grpc.WithBlock()}
+	if t.compression == CompressionGzip {
+		options = append(options, grpc.WithDefaultCallOptions(grpc.UseCompressor(CompressionGzip)))
+	}
+	return options
+}
+
+// httpMetricOptions returns the options of the client exporting the metrics over http
+func (t *telemetry) httpMetricOptions() []otlpmetrichttp.Option {
+	options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(t.endpoint)}
+	if t.tlsConfig != nil {
+		options = append(options, otlpmetrichttp.WithTLSClientConfig(t.tlsConfig))
+	} else {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+	if len(t.headers) > 0 {
+		options = append(options, otlpmetrichttp.WithHeaders(t.headers))
+	}
+	if t.timeout > 0 {
+		options = append(options, otlpmetrichttp.WithTimeout(t.timeout))
+	}
+	if t.compression == CompressionGzip {
+		options = append(options, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	return options
+}
+
+// grpcMetricOptions returns the options of the client exporting the metrics over the grpc connection conn
+func (t *telemetry) grpcMetricOptions(conn *grpc.ClientConn) []otlpmetricgrpc.Option {
+	options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithGRPCConn(conn)}
+	if len(t.headers) > 0 {
+		options = append(options, otlpmetricgrpc.WithHeaders(t.headers))
+	}
+	if t.timeout > 0 {
+		options = append(options, otlpmetricgrpc.WithTimeout(t.timeout))
+	}
+	return options
+}
+
+// grpcClientOptions returns the options of the client exporting the spans over the grpc connection conn
+func (t *telemetry) grpcClientOptions(conn *grpc.ClientConn) []otlptracegrpc.Option {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithGRPCConn(conn)}
+	if len(t.headers) > 0 {
+		options = append(options, otlptracegrpc.WithHeaders(t.headers))
+	}
+	if t.timeout > 0 {
+		options = append(options, otlptracegrpc.WithTimeout(t.timeout))
+	}
+	return options
+}
+
 // Initiates the trace provider with proper resources, exporter information
 // and span processors
 func (t *telemetry) Start() (Telemetry, error) {
 
-	if t.isOTLPEnabled() {
+	if t.hasExporter() {
 
-		var exporter *otlptrace.Exporter
+		var exporter sdktrace.SpanExporter
+		// the metrics are exported to the collector along with the spans
+		// unless the user has set a meter provider
+		var metricExporter sdkmetric.Exporter
 		var err error
 
 		if t.transport == "HTTP" {
@@ -112,10 +628,7 @@ func (t *telemetry) Start() (Telemetry, error) {
 			// creating exporter which communicates with the OTLP collector
 			exporter, err = otlptrace.New(
 				context.Background(),
-				otlptracehttp.NewClient(
-					otlptracehttp.WithInsecure(),
-					otlptracehttp.WithEndpoint(t.endpoint),
-				),
+				otlptracehttp.NewClient(t.httpClientOptions()...),
 			)
 
 			// raising error if exporter creation had some issues
@@ -123,25 +636,50 @@ func (t *telemetry) Start() (Telemetry, error) {
 				return nil, fmt.Errorf("Error creating OTLP trace exporter: %v\n", err)
 			}
 
+			if t.meterProvider == nil {
+				metricExporter, err = otlpmetrichttp.New(context.Background(), t.httpMetricOptions()...)
+				if err != nil {
+					return nil, fmt.Errorf("Error creating OTLP metric exporter: %v", err)
+				}
+			}
+
 		} else if t.transport == "GRPC" {
 
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			connectTimeout := time.Second
+			if t.timeout > 0 {
+				connectTimeout = t.timeout
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
 			defer cancel()
-			conn, err := grpc.DialContext(ctx, t.endpoint,
-				grpc.WithTransportCredentials(insecure.NewCredentials()),
-				grpc.WithBlock(),
-			)
+			conn, err := grpc.DialContext(ctx, t.endpoint, t.grpcDialOptions()...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
 			}
 
-			exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+			exporter, err = otlptracegrpc.New(ctx, t.grpcClientOptions(conn)...)
 
 			// raising error if exporter creation had some issues
 			if err != nil {
 				return nil, fmt.Errorf("Error creating OTLP trace exporter: %v\n", err)
 			}
 
+			if t.meterProvider == nil {
+				metricExporter, err = otlpmetricgrpc.New(ctx, t.grpcMetricOptions(conn)...)
+				if err != nil {
+					return nil, fmt.Errorf("Error creating OTLP metric exporter: %v", err)
+				}
+			}
+
+		} else if t.transport == "STDOUT" {
+			exporter = &jsonSpanExporter{encoder: json.NewEncoder(os.Stdout)}
+		} else if t.transport == "FILE" {
+			file, err := os.Create(t.filePath)
+			if err != nil {
+				return nil, fmt.Errorf("Error creating the span file: %v", err)
+			}
+			exporter = &jsonSpanExporter{encoder: json.NewEncoder(file), closer: file}
+		} else if t.transport == "MEMORY" {
+			exporter = t.memory
 		} else {
 			return nil, fmt.Errorf("transport %s is not supported", t.transport)
 		}
@@ -161,16 +699,19 @@ func (t *telemetry) Start() (Telemetry, error) {
 
 		var spanProcessor sdktrace.SpanProcessor
 
-		// by default we use BatchSpanProcessor
+		// by default we use BatchSpanProcessor, the spans kept in memory
+		// are processed as soon as they end so that they can be inspected
 		if t.spanProcessor != nil {
 			spanProcessor = t.spanProcessor
+		} else if t.transport == "MEMORY" {
+			spanProcessor = sdktrace.NewSimpleSpanProcessor(exporter)
 		} else {
 			spanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
 		}
 
 		// Creating the traceProvider
 		traceProvider := sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.AlwaysSample()),
+			sdktrace.WithSampler(t.sampler()),
 			sdktrace.WithSpanProcessor(spanProcessor),
 			sdktrace.WithResource(resources),
 		)
@@ -186,6 +727,19 @@ func (t *telemetry) Start() (Telemetry, error) {
 		t.traceProvider = traceProvider
 		tracer = otel.Tracer("gosnappi-tracer")
 
+		if metricExporter != nil {
+			meterProvider := sdkmetric.NewMeterProvider(
+				sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+				sdkmetric.WithResource(resources),
+			)
+			metrics, err := newClientMetrics(meterProvider)
+			if err != nil {
+				return nil, fmt.Errorf("Error creating the metrics of the operations: %v", err)
+			}
+			t.otlpMeterProvider = meterProvider
+			t.metrics = metrics
+		}
+
 		return t, nil
 	}
 
@@ -198,6 +752,14 @@ func (t *telemetry) Stop() {
 		if err := t.traceProvider.Shutdown(context.Background()); err != nil {
 			logs.Error("Failed shutting down trace provider")
 		}
+		t.traceProvider = nil
+		if t.otlpMeterProvider != nil {
+			if err := t.otlpMeterProvider.Shutdown(context.Background()); err != nil {
+				logs.Error("Failed shutting down meter provider")
+			}
+			t.otlpMeterProvider = nil
+			t.metrics = nil
+		}
 		logs.Info("Stopping tracing")
 	}
 }
@@ -239,3 +801,320 @@ func (t *telemetry) SetSpanEvent(span trace.Span, eventStr string) {
 		span.AddEvent(eventStr)
 	}
 }
+
+// jsonSpanExporter writes the spans as json, one span per line
+type jsonSpanExporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+func (e *jsonSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, span := range spans {
+		if err := e.encoder.Encode(newSpanSnapshot(span)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonSpanExporter) Shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+// SpanSnapshot is a copy of an ended span as kept by the in-memory exporter
+type SpanSnapshot struct {
+	Name                 string
+	SpanContext          trace.SpanContext
+	Parent               trace.SpanContext
+	SpanKind             trace.SpanKind
+	StartTime            time.Time
+	EndTime              time.Time
+	Attributes           []attribute.KeyValue
+	Events               []sdktrace.Event
+	Links                []sdktrace.Link
+	Status               sdktrace.Status
+	DroppedAttributes    int
+	DroppedEvents        int
+	DroppedLinks         int
+	ChildSpanCount       int
+	Resource             *resource.Resource
+	InstrumentationScope instrumentation.Scope
+}
+
+// SpanSnapshots is the list of the spans kept by the in-memory exporter in the order they ended
+type SpanSnapshots []SpanSnapshot
+
+func newSpanSnapshot(span sdktrace.ReadOnlySpan) SpanSnapshot {
+	return SpanSnapshot{
+		Name:                 span.Name(),
+		SpanContext:          span.SpanContext(),
+		Parent:               span.Parent(),
+		SpanKind:             span.SpanKind(),
+		StartTime:            span.StartTime(),
+		EndTime:              span.EndTime(),
+		Attributes:           span.Attributes(),
+		Events:               span.Events(),
+		Links:                span.Links(),
+		Status:               span.Status(),
+		DroppedAttributes:    span.DroppedAttributes(),
+		DroppedEvents:        span.DroppedEvents(),
+		DroppedLinks:         span.DroppedLinks(),
+		ChildSpanCount:       span.ChildSpanCount(),
+		Resource:             span.Resource(),
+		InstrumentationScope: span.InstrumentationScope(),
+	}
+}
+
+// memorySpanExporter keeps the spans exported in memory, even once it is shut down
+type memorySpanExporter struct {
+	mutex     sync.Mutex
+	snapshots SpanSnapshots
+}
+
+func (e *memorySpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, span := range spans {
+		e.snapshots = append(e.snapshots, newSpanSnapshot(span))
+	}
+	return nil
+}
+
+func (e *memorySpanExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *memorySpanExporter) spans() SpanSnapshots {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append(SpanSnapshots{}, e.snapshots...)
+}
+
+func (e *memorySpanExporter) reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.snapshots = nil
+}
+
+// clientMetrics records the metrics of the calls of the operations of an Api,
+// labeled by operation and transport
+type clientMetrics struct {
+	duration           metric.Float64Histogram
+	requestSize        metric.Int64Histogram
+	responseSize       metric.Int64Histogram
+	errors             metric.Int64Counter
+	chunks             metric.Int64Counter
+	validationFailures metric.Int64Counter
+}
+
+func newClientMetrics(provider metric.MeterProvider) (*clientMetrics, error) {
+	meter := provider.Meter("gosnappi-meter")
+	obj := &clientMetrics{}
+	var err error
+	if obj.duration, err = meter.Float64Histogram("rpc.client.duration", metric.WithUnit("s"),
+		metric.WithDescription("The duration of the calls of the operations")); err != nil {
+		return nil, err
+	}
+	if obj.requestSize, err = meter.Int64Histogram("rpc.client.request.size", metric.WithUnit("By"),
+		metric.WithDescription("The size of the request messages, one per chunk of a stream")); err != nil {
+		return nil, err
+	}
+	if obj.responseSize, err = meter.Int64Histogram("rpc.client.response.size", metric.WithUnit("By"),
+		metric.WithDescription("The size of the response messages, one per chunk of a stream")); err != nil {
+		return nil, err
+	}
+	if obj.errors, err = meter.Int64Counter("rpc.client.errors", metric.WithUnit("{call}"),
+		metric.WithDescription("The calls of the operations which have failed")); err != nil {
+		return nil, err
+	}
+	if obj.chunks, err = meter.Int64Counter("rpc.client.stream.chunks", metric.WithUnit("{chunk}"),
+		metric.WithDescription("The chunks sent and received by the streaming operations")); err != nil {
+		return nil, err
+	}
+	if obj.validationFailures, err = meter.Int64Counter("rpc.client.validation.failures", metric.WithUnit("{request}"),
+		metric.WithDescription("The requests which have not been sent as they are invalid")); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// operationAttributes returns the labels of the metrics of operation sent using transport
+func operationAttributes(operation string, transport string, extra ...attribute.KeyValue) attribute.Set {
+	return attribute.NewSet(append([]attribute.KeyValue{
+		attribute.String("operation", operation),
+		attribute.String("transport", transport),
+	}, extra...)...)
+}
+
+// startCall returns ctx carrying the measurement of a call of operation,
+// the transports record the messages of the call using the measurement
+func (obj *clientMetrics) startCall(ctx context.Context, operation string, transport string) (context.Context, *callMeasurement) {
+	measurement := &callMeasurement{
+		metrics:   obj,
+		operation: operation,
+		transport: transport,
+		attrs:     operationAttributes(operation, transport),
+	}
+	return context.WithValue(ctx, callMeasurementKey{}, measurement), measurement
+}
+
+// validationFailed counts a request of operation which is invalid
+func (obj *clientMetrics) validationFailed(ctx context.Context, operation string, transport string) {
+	obj.validationFailures.Add(ctx, 1, metric.WithAttributeSet(operationAttributes(operation, transport)))
+}
+
+// callMeasurementKey is the context key of the measurement of a call
+type callMeasurementKey struct{}
+
+// callMeasurement measures a call of an operation, its methods do nothing when it is nil
+type callMeasurement struct {
+	metrics   *clientMetrics
+	operation string
+	transport string
+	attrs     attribute.Set
+	// httpStatus is the status of the last http response of the call
+	httpStatus int64
+}
+
+// measurementFromContext returns the measurement of the call carried by ctx, nil if there is none
+func measurementFromContext(ctx context.Context) *callMeasurement {
+	measurement, _ := ctx.Value(callMeasurementKey{}).(*callMeasurement)
+	return measurement
+}
+
+func (obj *callMeasurement) requestSent(ctx context.Context, size int) {
+	if obj != nil {
+		obj.metrics.requestSize.Record(ctx, int64(size), metric.WithAttributeSet(obj.attrs))
+	}
+}
+
+func (obj *callMeasurement) responseReceived(ctx context.Context, size int) {
+	if obj != nil {
+		obj.metrics.responseSize.Record(ctx, int64(size), metric.WithAttributeSet(obj.attrs))
+	}
+}
+
+// chunk counts a chunk of a stream, direction is either sent or received
+func (obj *callMeasurement) chunk(ctx context.Context, direction string) {
+	if obj != nil {
+		obj.metrics.chunks.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("operation", obj.operation),
+			attribute.String("transport", obj.transport),
+			attribute.String("direction", direction),
+		))
+	}
+}
+
+func (obj *callMeasurement) setHttpStatus(value int) {
+	if obj != nil {
+		atomic.StoreInt64(&obj.httpStatus, int64(value))
+	}
+}
+
+// end records the duration of the call and counts it as failed when err is set,
+// labeled by the grpc code or the http status of the failure when there is one
+func (obj *callMeasurement) end(ctx context.Context, duration time.Duration, err error) {
+	if obj == nil {
+		return
+	}
+	obj.metrics.duration.Record(ctx, duration.Seconds(), metric.WithAttributeSet(obj.attrs))
+	if err == nil {
+		return
+	}
+	var code []attribute.KeyValue
+	var coded interface{ Code() int32 }
+	if status := atomic.LoadInt64(&obj.httpStatus); status != 0 {
+		code = append(code, attribute.Int64("http.response.status_code", status))
+	} else if errors.As(err, &coded) && obj.transport == "grpc" {
+		code = append(code, attribute.Int64("rpc.grpc.status_code", int64(coded.Code())))
+	}
+	obj.metrics.errors.Add(ctx, 1, metric.WithAttributeSet(operationAttributes(obj.operation, obj.transport, code...)))
+}
+
+// measuredBody records the size of a response body once it has been read or closed
+type measuredBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	measurement *callMeasurement
+	size        int
+	once        sync.Once
+}
+
+func (obj *measuredBody) Read(p []byte) (int, error) {
+	n, err := obj.ReadCloser.Read(p)
+	obj.size += n
+	if err == io.EOF {
+		obj.record()
+	}
+	return n, err
+}
+
+func (obj *measuredBody) Close() error {
+	obj.record()
+	return obj.ReadCloser.Close()
+}
+
+func (obj *measuredBody) record() {
+	obj.once.Do(func() {
+		obj.measurement.responseReceived(obj.ctx, obj.size)
+	})
+}
+
+// grpcMetricsHandler measures the messages of the grpc calls made for the operations
+type grpcMetricsHandler struct{}
+
+// grpcStreamKey is the context key of whether a grpc call streams its requests or its responses
+type grpcStreamKey struct{}
+
+type grpcStream struct {
+	client int32
+	server int32
+}
+
+func (grpcMetricsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if measurementFromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, grpcStreamKey{}, &grpcStream{})
+}
+
+func (grpcMetricsHandler) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {
+	measurement := measurementFromContext(ctx)
+	stream, ok := ctx.Value(grpcStreamKey{}).(*grpcStream)
+	if measurement == nil || !ok {
+		return
+	}
+	switch s := rpcStats.(type) {
+	case *stats.Begin:
+		if s.IsClientStream {
+			atomic.StoreInt32(&stream.client, 1)
+		}
+		if s.IsServerStream {
+			atomic.StoreInt32(&stream.server, 1)
+		}
+	case *stats.OutPayload:
+		measurement.requestSent(ctx, s.Length)
+		if atomic.LoadInt32(&stream.client) == 1 {
+			measurement.chunk(ctx, "sent")
+		}
+	case *stats.InPayload:
+		measurement.responseReceived(ctx, s.Length)
+		if atomic.LoadInt32(&stream.server) == 1 {
+			measurement.chunk(ctx, "received")
+		}
+	}
+}
+
+func (grpcMetricsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcMetricsHandler) HandleConn(ctx context.Context, connStats stats.ConnStats) {}
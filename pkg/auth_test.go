@@ -0,0 +1,155 @@
+package openapiart_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockApi pairs an api with a lookup of the credentials received by its mock server
+type mockApi struct {
+	api      openapiart.Api
+	received func(key string) string
+}
+
+// newMockApis returns a grpc and a http api talking to the mock servers using auth
+func newMockApis(auth openapiart.Authenticator) []mockApi {
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewGrpcTransport().SetLocation(grpcServer.Location)
+	grpcApi.SetAuthenticator(auth)
+	httpApi := openapiart.NewApi()
+	httpApi.NewHttpTransport().SetLocation(httpServer.Location)
+	httpApi.SetAuthenticator(auth)
+	return []mockApi{
+		{
+			api: grpcApi,
+			received: func(key string) string {
+				values := grpcServer.Metadata().Get(strings.ToLower(key))
+				if len(values) == 0 {
+					return ""
+				}
+				return values[0]
+			},
+		},
+		{
+			api: httpApi,
+			received: func(key string) string {
+				return httpServer.Header().Get(key)
+			},
+		},
+	}
+}
+
+func TestBearerAuthenticator(t *testing.T) {
+	for _, a := range newMockApis(openapiart.NewBearerAuthAuthenticator("token-1")) {
+		_, err := a.api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-1", a.received("Authorization"))
+	}
+}
+
+func TestBasicAuthenticator(t *testing.T) {
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret"))
+	for _, a := range newMockApis(openapiart.NewBasicAuthAuthenticator("admin", "secret")) {
+		config := NewFullyPopulatedPrefixConfig(a.api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		_, err := a.api.SetConfig(config)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, a.received("Authorization"))
+	}
+}
+
+func TestApiKeyAuthenticator(t *testing.T) {
+	for _, a := range newMockApis(openapiart.NewApiKeyAuthAuthenticator("key-1")) {
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		_, err := a.api.GetMetrics(metReq)
+		assert.Nil(t, err)
+		assert.Equal(t, "key-1", a.received("X-API-Key"))
+	}
+}
+
+func TestAuthenticatorSecuritySchemes(t *testing.T) {
+	// GetMetrics only accepts apiKeyAuth and ClearWarnings requires no credentials
+	for _, a := range newMockApis(openapiart.NewBearerAuthAuthenticator("token-1")) {
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		_, err := a.api.GetMetrics(metReq)
+		assert.Nil(t, err)
+		assert.Equal(t, "", a.received("Authorization"))
+
+		_, err = a.api.ClearWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "", a.received("Authorization"))
+	}
+}
+
+func TestTokenAuthenticatorRefresh(t *testing.T) {
+	for _, a := range newMockApis(nil) {
+		refreshes := 0
+		expiry := time.Now().Add(-time.Second)
+		a.api.SetAuthenticator(openapiart.NewOauth2AuthAuthenticator(func(ctx context.Context) (string, time.Time, error) {
+			refreshes++
+			return fmt.Sprintf("token-%d", refreshes), expiry, nil
+		}))
+		_, err := a.api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-1", a.received("Authorization"))
+
+		// the token has expired so the source is called again
+		expiry = time.Now().Add(time.Hour)
+		_, err = a.api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-2", a.received("Authorization"))
+
+		// the cached token is still valid
+		_, err = a.api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-2", a.received("Authorization"))
+		assert.Equal(t, 2, refreshes)
+	}
+}
+
+func TestAuthenticatorFunc(t *testing.T) {
+	var operations []string
+	auth := openapiart.AuthenticatorFunc(func(ctx context.Context, operation string) (map[string]string, error) {
+		operations = append(operations, operation)
+		return map[string]string{"X-Operation": operation}, nil
+	})
+	for _, a := range newMockApis(auth) {
+		operations = nil
+		_, err := a.api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "GetWarnings", a.received("X-Operation"))
+
+		// credentials of unbound authenticators are sent with every operation declaring security
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		_, err = a.api.GetMetrics(metReq)
+		assert.Nil(t, err)
+		assert.Equal(t, "GetMetrics", a.received("X-Operation"))
+
+		// ClearWarnings is declared without security so no authenticator is called
+		_, err = a.api.ClearWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, "", a.received("X-Operation"))
+		assert.Equal(t, []string{"GetWarnings", "GetMetrics"}, operations)
+	}
+}
+
+func TestAuthenticatorError(t *testing.T) {
+	auth := openapiart.NewTokenAuthenticator(func(ctx context.Context) (string, time.Time, error) {
+		return "", time.Time{}, fmt.Errorf("token endpoint unreachable")
+	})
+	for _, a := range newMockApis(auth) {
+		_, err := a.api.GetWarnings()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "could not get credentials for GetWarnings: token endpoint unreachable")
+	}
+}
@@ -0,0 +1,134 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// methodRecorder records the grpc methods called through a client connection
+type methodRecorder struct {
+	mutex   sync.Mutex
+	methods []string
+}
+
+func (r *methodRecorder) record(method string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.methods = append(r.methods, method)
+}
+
+func (r *methodRecorder) called() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.methods...)
+}
+
+// recordingApi returns a grpc api and its transport whose calls are recorded by the returned recorder
+func recordingApi(t *testing.T) (openapiart.Api, openapiart.GrpcTransport, *methodRecorder) {
+	recorder := &methodRecorder{}
+	conn, err := grpc.Dial(grpcServer.Location,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			recorder.record(method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			recorder.record(method)
+			return streamer(ctx, desc, cc, method, opts...)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetClientConnection(conn)
+	return api, transport, recorder
+}
+
+func TestGrpcAutoStreaming(t *testing.T) {
+	api, transport, recorder := recordingApi(t)
+	transport.EnableAutoGrpcStreaming()
+	assert.Equal(t, 4*megabyte, transport.MaxSendMsgSize())
+
+	_, err := api.UploadConfig([]byte("small config"))
+	assert.Nil(t, err)
+	data := bytes.Repeat([]byte("a"), 5*megabyte)
+	_, err = api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+	assert.Equal(t, []string{"/sanity.Openapi/UploadConfig", "/sanity.Openapi/streamUploadConfig"}, recorder.called())
+}
+
+func TestGrpcAutoStreamingChunkFitsMessage(t *testing.T) {
+	api, transport, _ := recordingApi(t)
+	progress := &progressRecorder{}
+	transport.EnableAutoGrpcStreaming().SetMaxSendMsgSize(megabyte).SetUploadProgress(progress.record)
+
+	// the default chunk size is larger than the maximum message size
+	data := bytes.Repeat([]byte("a"), 3*megabyte)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+	var previous uint64
+	for _, p := range progress.reported() {
+		assert.LessOrEqual(t, p.Sent-previous, uint64(megabyte))
+		previous = p.Sent
+	}
+	assert.Equal(t, uint64(len(data)), previous)
+}
+
+func TestGrpcStreamingSmallMaxSendMsgSize(t *testing.T) {
+	api, transport, _ := recordingApi(t)
+	progress := &progressRecorder{}
+	transport.EnableGrpcStreaming().SetMaxSendMsgSize(1024).SetUploadProgress(progress.record)
+
+	// the chunks are capped even when the maximum message size leaves little room
+	data := bytes.Repeat([]byte("a"), 2048)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+	var previous uint64
+	for _, p := range progress.reported() {
+		assert.LessOrEqual(t, p.Sent-previous, uint64(512))
+		previous = p.Sent
+	}
+	assert.Equal(t, uint64(len(data)), previous)
+}
+
+func TestGrpcMaxSendMsgSize(t *testing.T) {
+	api, transport, recorder := recordingApi(t)
+	transport.SetMaxSendMsgSize(1024)
+	assert.Equal(t, 1024, transport.MaxSendMsgSize())
+	// the sizes that are not positive are ignored
+	assert.Equal(t, 1024, transport.SetMaxSendMsgSize(0).MaxSendMsgSize())
+	assert.Equal(t, 1024, transport.SetMaxSendMsgSize(-1).MaxSendMsgSize())
+
+	_, err := api.UploadConfig(bytes.Repeat([]byte("a"), 2048))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "larger than max")
+	assert.Equal(t, []string{"/sanity.Openapi/UploadConfig"}, recorder.called())
+}
+
+func TestGrpcMaxRecvMsgSize(t *testing.T) {
+	api, transport, _ := recordingApi(t)
+	transport.SetMaxRecvMsgSize(1)
+	assert.Equal(t, 1, transport.MaxRecvMsgSize())
+	assert.Equal(t, 1, transport.SetMaxRecvMsgSize(0).MaxRecvMsgSize())
+
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	_, err := api.GetMetrics(metReq)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "larger than max")
+
+	transport.SetMaxRecvMsgSize(4 * megabyte)
+	_, err = api.GetMetrics(metReq)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,150 @@
+package openapiart_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// session is a sequence of calls whose results are compared between recording and replay
+func session(t *testing.T, api openapiart.Api) []string {
+	var results []string
+	add := func(value string, err error) {
+		if err != nil {
+			value = "error: " + err.Error()
+		}
+		results = append(results, value)
+	}
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	add(string(resp), err)
+
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_400)
+	_, err = api.SetConfig(config)
+	assert.NotNil(t, err)
+	add("", err)
+
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	metrics, err := api.GetMetrics(metReq)
+	assert.Nil(t, err)
+	add(metrics.Marshal().ToJson())
+
+	warnings, err := api.UploadConfig([]byte("uploaded config"))
+	assert.Nil(t, err)
+	add(warnings.Marshal().ToJson())
+
+	reader, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+	capture, err := io.ReadAll(reader)
+	assert.Nil(t, reader.Close())
+	add(string(capture), err)
+	return results
+}
+
+func TestRecordReplay(t *testing.T) {
+	for i, api := range apis[:2] {
+		path := filepath.Join(t.TempDir(), "session.jsonl")
+		assert.Nil(t, api.StartRecording(path))
+		recorded := session(t, api)
+		assert.Nil(t, api.StopRecording())
+
+		replayApi := openapiart.NewApi()
+		var transports []string
+		replayApi.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+			transports = append(transports, invocation.Transport)
+			return next(ctx, invocation)
+		})
+		replay, err := replayApi.NewReplayTransport(path)
+		assert.Nil(t, err)
+		assert.Equal(t, path, replay.Path())
+		entries := replay.Entries()
+		assert.Len(t, entries, 5)
+		assert.Equal(t, "SetConfig", entries[0].Operation)
+		assert.Equal(t, []string{"grpc", "http"}[i], entries[0].Transport)
+		assert.NotNil(t, entries[1].Error)
+		assert.Equal(t, "GetCaptureStream", entries[4].Operation)
+
+		assert.Equal(t, recorded, session(t, replayApi))
+		assert.Equal(t, []string{"replay", "replay", "replay", "replay", "replay"}, transports)
+	}
+}
+
+func TestReplayUnrecordedRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	assert.Nil(t, api.StartRecording(path))
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	_, err := api.GetMetrics(metReq)
+	assert.Nil(t, err)
+	assert.Nil(t, api.Close())
+
+	_, err = api.NewReplayTransport(path)
+	assert.Nil(t, err)
+	metReq.SetPort("p2")
+	_, err = api.GetMetrics(metReq)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no call of GetMetrics")
+}
+
+// writeCassette writes entries to a cassette file and returns its path
+func writeCassette(t *testing.T, entries ...openapiart.CassetteEntry) string {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		assert.Nil(t, encoder.Encode(entry))
+	}
+	return path
+}
+
+func TestReplayOrder(t *testing.T) {
+	path := writeCassette(t,
+		openapiart.CassetteEntry{Operation: "GetWarnings", Response: json.RawMessage(`{"warnings":["first"]}`)},
+		openapiart.CassetteEntry{Operation: "GetWarnings", Response: json.RawMessage(`{"warnings":["second"]}`)},
+	)
+	api := openapiart.NewApi()
+	_, err := api.NewReplayTransport(path)
+	assert.Nil(t, err)
+	for _, expected := range []string{"first", "second", "second"} {
+		warnings, err := api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{expected}, warnings.Warnings())
+	}
+}
+
+func TestReplayRealtime(t *testing.T) {
+	path := writeCassette(t,
+		openapiart.CassetteEntry{Operation: "GetWarnings", Response: json.RawMessage(`{}`), Duration: 50 * time.Millisecond},
+	)
+	api := openapiart.NewApi()
+	replay, err := api.NewReplayTransport(path)
+	assert.Nil(t, err)
+	replay.SetRealtime(true)
+	assert.True(t, replay.Realtime())
+
+	start := time.Now()
+	_, err = api.GetWarnings()
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = api.GetWarningsCtx(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
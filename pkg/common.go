@@ -1,26 +1,77 @@
 package openapiart
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type grpcTransport struct {
 	clientConnection    *grpc.ClientConn
-	location            string
+	endpoints           *endpoints
+	connections         *grpcConnections
 	requestTimeout      time.Duration
 	dialTimeout         time.Duration
 	enableGrpcStreaming bool
+	autoGrpcStreaming   bool
 	chunkSize           uint64
+	maxSendMsgSize      int
+	maxRecvMsgSize      int
+	tlsConfig           *tls.Config
+	caCertFile          string
+	certFile            string
+	keyFile             string
+	serverName          string
+	retryPolicy         *retryPolicy
+	uploadProgress      UploadProgressFunc
+	uploadRestarts      int
+	compression         string
+	keepAlive           keepalive.ClientParameters
+	healthService       string
+	reconnectTimeout    time.Duration
+	// loopback dials the in-memory connection of a loopback transport
+	loopback func(ctx context.Context) (net.Conn, error)
 }
 
 type GrpcTransport interface {
@@ -28,6 +79,21 @@ type GrpcTransport interface {
 	SetLocation(value string) GrpcTransport
 	// Location get grpc target
 	Location() string
+	// SetLocations sets the ordered grpc targets of redundant servers, a request is sent to the
+	// next target when the active one cannot be reached
+	SetLocations(value []string) GrpcTransport
+	// Locations get ordered grpc targets
+	Locations() []string
+	// ActiveLocation get grpc target which has answered the last request
+	ActiveLocation() string
+	// EnableRoundRobin spreads the requests over the grpc targets in turn
+	EnableRoundRobin() GrpcTransport
+	// DisableRoundRobin sends the requests to the active grpc target, which is the default
+	DisableRoundRobin() GrpcTransport
+	// SetFailoverCooldown sets how long a grpc target which could not be reached is skipped, it defaults to 30 seconds
+	SetFailoverCooldown(value time.Duration) GrpcTransport
+	// FailoverCooldown get how long a grpc target which could not be reached is skipped
+	FailoverCooldown() time.Duration
 	// SetRequestTimeout set timeout in grpc request
 	SetRequestTimeout(value time.Duration) GrpcTransport
 	// RequestTimeout get timeout in grpc request
@@ -45,23 +111,134 @@ type GrpcTransport interface {
 	EnableGrpcStreaming() GrpcTransport
 	// DisableGrpcStreaming disables streaming of data through GRPC channel
 	DisableGrpcStreaming() GrpcTransport
+	// EnableAutoGrpcStreaming streams the data of a client streaming operation only when its request
+	// is larger than the maximum send message size and sends it as a unary request otherwise
+	EnableAutoGrpcStreaming() GrpcTransport
+	// SetMaxSendMsgSize sets the maximum size in bytes of a message sent to the server, it defaults to 4MB
+	SetMaxSendMsgSize(value int) GrpcTransport
+	// MaxSendMsgSize get maximum size in bytes of a message sent to the server
+	MaxSendMsgSize() int
+	// SetMaxRecvMsgSize sets the maximum size in bytes of a message received from the server, it defaults to 4MB
+	SetMaxRecvMsgSize(value int) GrpcTransport
+	// MaxRecvMsgSize get maximum size in bytes of a message received from the server
+	MaxRecvMsgSize() int
 	// SetStreamChunkSize sets the chunk size, basically this decides your data will be sliced into how many chunks before streaming it to the server
 	// we accept value in MB so if you set 1 we will consider it as 1MB
 	SetStreamChunkSize(value uint64) GrpcTransport
+	// SetTLSConfig enables TLS on the grpc connection using the given tls configuration
+	// CA certificate, client certificate and server name set on the transport take precedence over it
+	SetTLSConfig(value *tls.Config) GrpcTransport
+	// TLSConfig get tls configuration used for the grpc connection
+	TLSConfig() *tls.Config
+	// SetCACertFile enables TLS and verifies the server against the PEM encoded CA certificates in the given file
+	SetCACertFile(value string) GrpcTransport
+	// CACertFile get PEM encoded CA certificate file
+	CACertFile() string
+	// SetClientCertificate enables mutual TLS using the PEM encoded certificate and key files
+	SetClientCertificate(certFile string, keyFile string) GrpcTransport
+	// SetServerName enables TLS and overrides the server name used for SNI and certificate verification
+	SetServerName(value string) GrpcTransport
+	// ServerName get server name used for SNI and certificate verification
+	ServerName() string
+	// SetRetryPolicy sets the policy used to retry requests failing with a retryable status code
+	SetRetryPolicy(value RetryPolicy) GrpcTransport
+	// RetryPolicy get policy used to retry failed requests
+	RetryPolicy() RetryPolicy
+	// SetUploadProgress sets the callback reporting the progress of streamed uploads
+	SetUploadProgress(value UploadProgressFunc) GrpcTransport
+	// SetResumableUploads restarts a streamed upload failing with a retryable status code up to maxRestarts times,
+	// it is resumed from the offset acknowledged by the server which has to support resumable uploads e.g. using an UploadStore
+	SetResumableUploads(maxRestarts int) GrpcTransport
+	// ResumableUploads get maximum number of restarts of a streamed upload, zero when uploads are not resumable
+	ResumableUploads() int
+	// SetCompression compresses the messages sent to the server using one of
+	// CompressionGzip, CompressionZstd or CompressionNone which is the default
+	SetCompression(value string) GrpcTransport
+	// Compression get compression of the messages sent to the server
+	Compression() string
+	// SetKeepAlive sets the keepalive pings of the grpc connections, a zero Time disables them which is the default
+	SetKeepAlive(value keepalive.ClientParameters) GrpcTransport
+	// KeepAlive get keepalive parameters of the grpc connections
+	KeepAlive() keepalive.ClientParameters
+	// SetHealthService sets the service whose status is checked with the grpc health protocol by WaitUntilReady,
+	// it defaults to the empty name standing for the overall health of the server
+	SetHealthService(value string) GrpcTransport
+	// HealthService get service whose status is checked by WaitUntilReady
+	HealthService() string
+	// SetReconnectTimeout sets how long a request failing fast while its connection is down waits for the connection
+	// to be re-established before being sent again, it defaults to 1 second and zero disables the reconnection
+	SetReconnectTimeout(value time.Duration) GrpcTransport
+	// ReconnectTimeout get how long a request waits for its connection to be re-established
+	ReconnectTimeout() time.Duration
 }
 
 // Location
 func (obj *grpcTransport) Location() string {
-	logs.Debug("", "Location", obj.location)
-	return obj.location
+	location := obj.endpoints.Locations()[0]
+	logs.Debug("", "Location", location)
+	return location
 }
 
 // SetLocation
 func (obj *grpcTransport) SetLocation(value string) GrpcTransport {
-	obj.location = value
+	obj.endpoints.setLocations([]string{value})
 	return obj
 }
 
+// SetLocations sets the ordered grpc targets of redundant servers
+func (obj *grpcTransport) SetLocations(value []string) GrpcTransport {
+	if len(value) == 0 {
+		fmt.Println("No location has been provided, so will not be considered")
+		return obj
+	}
+	obj.endpoints.setLocations(value)
+	return obj
+}
+
+// Locations returns the ordered grpc targets
+func (obj *grpcTransport) Locations() []string {
+	return obj.endpoints.Locations()
+}
+
+// ActiveLocation returns the grpc target which has answered the last request
+func (obj *grpcTransport) ActiveLocation() string {
+	return obj.endpoints.activeLocation()
+}
+
+// EnableRoundRobin spreads the requests over the grpc targets in turn
+func (obj *grpcTransport) EnableRoundRobin() GrpcTransport {
+	obj.endpoints.setRoundRobin(true)
+	return obj
+}
+
+// DisableRoundRobin sends the requests to the active grpc target
+func (obj *grpcTransport) DisableRoundRobin() GrpcTransport {
+	obj.endpoints.setRoundRobin(false)
+	return obj
+}
+
+// SetFailoverCooldown sets how long a grpc target which could not be reached is skipped
+func (obj *grpcTransport) SetFailoverCooldown(value time.Duration) GrpcTransport {
+	obj.endpoints.setCooldown(value)
+	return obj
+}
+
+// FailoverCooldown returns how long a grpc target which could not be reached is skipped
+func (obj *grpcTransport) FailoverCooldown() time.Duration {
+	return obj.endpoints.Cooldown()
+}
+
+// unreachable tells whether err has been returned because the grpc target of the request could not be
+// reached, grpc errors have already been converted to the Error of the api which keeps their code
+func (obj *grpcTransport) unreachable(err error) bool {
+	var coded interface{ Code() int32 }
+	if errors.As(err, &coded) && coded.Code() == int32(grpcCodes.Unavailable) {
+		return true
+	}
+	var opErr *net.OpError
+	return status.Code(err) == grpcCodes.Unavailable || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
 // RequestTimeout returns the grpc request timeout in seconds
 func (obj *grpcTransport) RequestTimeout() time.Duration {
 	logs.Debug("", "RequestTimeout", obj.requestTimeout.String())
@@ -73,6 +250,16 @@ func (obj *grpcTransport) SetRequestTimeout(value time.Duration) GrpcTransport {
 	obj.requestTimeout = value
 	return obj
 }
+
+// requestContext derives the context of a single grpc request from ctx,
+// the request timeout is applied only when ctx carries no deadline of its own
+func (obj *grpcTransport) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, obj.requestTimeout)
+}
+
 func (obj *grpcTransport) DialTimeout() time.Duration {
 	logs.Debug("", "DialTimeout", obj.dialTimeout.String())
 	return obj.dialTimeout
@@ -83,7 +270,12 @@ func (obj *grpcTransport) SetDialTimeout(value time.Duration) GrpcTransport {
 	return obj
 }
 
+// ClientConnection returns the connection set by SetClientConnection
+// or the connection dialed to the active grpc target
 func (obj *grpcTransport) ClientConnection() *grpc.ClientConn {
+	if obj.clientConnection == nil && obj.connections != nil {
+		return obj.connections.active()
+	}
 	return obj.clientConnection
 }
 
@@ -96,15 +288,187 @@ func (obj *grpcTransport) SetClientConnection(con *grpc.ClientConn) GrpcTranspor
 // By default its disabled
 func (obj *grpcTransport) EnableGrpcStreaming() GrpcTransport {
 	obj.enableGrpcStreaming = true
+	obj.autoGrpcStreaming = false
 	return obj
 }
 
 // DisableGrpcStreaming disables streaming of data through GRPC channel
 func (obj *grpcTransport) DisableGrpcStreaming() GrpcTransport {
 	obj.enableGrpcStreaming = false
+	obj.autoGrpcStreaming = false
+	return obj
+}
+
+// EnableAutoGrpcStreaming streams the data of a client streaming operation only when needed
+// server streaming operations are not streamed in this mode as the size of a response is not known upfront
+func (obj *grpcTransport) EnableAutoGrpcStreaming() GrpcTransport {
+	obj.enableGrpcStreaming = false
+	obj.autoGrpcStreaming = true
+	return obj
+}
+
+// streamRequest tells whether a request of the given marshalled size is streamed
+func (obj *grpcTransport) streamRequest(size int) bool {
+	return obj.enableGrpcStreaming || (obj.autoGrpcStreaming && size > obj.maxSendMsgSize)
+}
+
+// SetMaxSendMsgSize sets the maximum size in bytes of a message sent to the server
+func (obj *grpcTransport) SetMaxSendMsgSize(value int) GrpcTransport {
+	if value <= 0 {
+		fmt.Printf("The maximum send message size %d is not positive, so will not be considered\n", value)
+		return obj
+	}
+	obj.maxSendMsgSize = value
+	return obj
+}
+
+// MaxSendMsgSize get maximum size in bytes of a message sent to the server
+func (obj *grpcTransport) MaxSendMsgSize() int {
+	return obj.maxSendMsgSize
+}
+
+// SetMaxRecvMsgSize sets the maximum size in bytes of a message received from the server
+func (obj *grpcTransport) SetMaxRecvMsgSize(value int) GrpcTransport {
+	if value <= 0 {
+		fmt.Printf("The maximum receive message size %d is not positive, so will not be considered\n", value)
+		return obj
+	}
+	obj.maxRecvMsgSize = value
+	return obj
+}
+
+// MaxRecvMsgSize get maximum size in bytes of a message received from the server
+func (obj *grpcTransport) MaxRecvMsgSize() int {
+	return obj.maxRecvMsgSize
+}
+
+// SetCompression compresses the messages sent to the server, the server replies using the same compression
+func (obj *grpcTransport) SetCompression(value string) GrpcTransport {
+	if !validCompression(value) {
+		fmt.Printf("The compression %s is not supported, so will not be considered. supported values are %s, %s and %s\n", value, CompressionNone, CompressionGzip, CompressionZstd)
+		return obj
+	}
+	obj.compression = value
+	return obj
+}
+
+// Compression returns the compression of the messages sent to the server
+func (obj *grpcTransport) Compression() string {
+	return obj.compression
+}
+
+// SetKeepAlive sets the keepalive pings of the grpc connections dialed by the Api
+func (obj *grpcTransport) SetKeepAlive(value keepalive.ClientParameters) GrpcTransport {
+	obj.keepAlive = value
+	return obj
+}
+
+// KeepAlive returns the keepalive parameters of the grpc connections
+func (obj *grpcTransport) KeepAlive() keepalive.ClientParameters {
+	return obj.keepAlive
+}
+
+// SetHealthService sets the service whose status is checked by WaitUntilReady
+func (obj *grpcTransport) SetHealthService(value string) GrpcTransport {
+	obj.healthService = value
+	return obj
+}
+
+// HealthService returns the service whose status is checked by WaitUntilReady
+func (obj *grpcTransport) HealthService() string {
+	return obj.healthService
+}
+
+// SetReconnectTimeout sets how long a request waits for its connection to be re-established
+func (obj *grpcTransport) SetReconnectTimeout(value time.Duration) GrpcTransport {
+	obj.reconnectTimeout = value
 	return obj
 }
 
+// ReconnectTimeout returns how long a request waits for its connection to be re-established
+func (obj *grpcTransport) ReconnectTimeout() time.Duration {
+	return obj.reconnectTimeout
+}
+
+// readyPollInterval is the delay between two checks of the locations of a transport by WaitUntilReady
+const readyPollInterval = 200 * time.Millisecond
+
+// waitUntilReady checks the locations in turn until one of them is ready or ctx is done
+func waitUntilReady(ctx context.Context, endpoints *endpoints, ready func(ctx context.Context, location string) error) error {
+	for {
+		var err error
+		for _, location := range endpoints.attemptOrder() {
+			if err = ready(ctx, location); err == nil {
+				endpoints.reached(location)
+				return nil
+			}
+			logs.Debug("location is not ready", "Location", location, "Error", err.Error())
+		}
+		timer := time.NewTimer(readyPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("no location is ready: %v", err)
+		case <-timer.C:
+		}
+	}
+}
+
+// waitUntilReady checks the health of the grpc targets until one of them is serving, a server which does not
+// implement the grpc health protocol is ready once it can be reached
+func (obj *grpcTransport) waitUntilReady(ctx context.Context) error {
+	return waitUntilReady(ctx, obj.endpoints, func(ctx context.Context, location string) error {
+		var conn grpc.ClientConnInterface = obj.connections
+		if obj.clientConnection != nil {
+			conn = obj.clientConnection
+		}
+		ctx, cancelFunc := obj.requestContext(context.WithValue(ctx, locationContextKey{}, location))
+		defer cancelFunc()
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: obj.healthService})
+		if status.Code(err) == grpcCodes.Unimplemented {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s is %s", location, resp.Status)
+		}
+		return nil
+	})
+}
+
+// callOptions applies the message size limits and the compression to a call, they are set
+// per call so that they also apply to a client connection set by the user
+func (obj *grpcTransport) callOptions() []grpc.CallOption {
+	opts := []grpc.CallOption{
+		grpc.MaxCallSendMsgSize(obj.maxSendMsgSize),
+		grpc.MaxCallRecvMsgSize(obj.maxRecvMsgSize),
+	}
+	if obj.compression != CompressionNone {
+		opts = append(opts, grpc.UseCompressor(obj.compression))
+	}
+	return opts
+}
+
+// streamChunkSize returns the chunk size capped so that a chunk fits in a message
+func (obj *grpcTransport) streamChunkSize() uint64 {
+	// leave room for the fields of the message wrapping the chunk,
+	// at most half of the message when the maximum size is small
+	headroom := 1024
+	if obj.maxSendMsgSize < 2*headroom {
+		headroom = obj.maxSendMsgSize / 2
+	}
+	limit := uint64(obj.maxSendMsgSize - headroom)
+	if limit == 0 {
+		limit = 1
+	}
+	if obj.chunkSize > limit {
+		return limit
+	}
+	return obj.chunkSize
+}
+
 // SetStreamChunkSize sets the chunk size, basically this decides your data will be sliced into how many chunks before streaming it to the server
 func (obj *grpcTransport) SetStreamChunkSize(value uint64) GrpcTransport {
 	if value > 17592186044415 {
@@ -115,129 +479,3015 @@ func (obj *grpcTransport) SetStreamChunkSize(value uint64) GrpcTransport {
 	return obj
 }
 
+// SetTLSConfig enables TLS on the grpc connection using the given tls configuration
+func (obj *grpcTransport) SetTLSConfig(value *tls.Config) GrpcTransport {
+	obj.tlsConfig = value
+	return obj
+}
+
+// TLSConfig returns the tls configuration used for the grpc connection
+func (obj *grpcTransport) TLSConfig() *tls.Config {
+	return obj.tlsConfig
+}
+
+// SetCACertFile enables TLS and verifies the server against the CA certificates in the given file
+func (obj *grpcTransport) SetCACertFile(value string) GrpcTransport {
+	obj.caCertFile = value
+	return obj
+}
+
+// CACertFile returns the CA certificate file
+func (obj *grpcTransport) CACertFile() string {
+	return obj.caCertFile
+}
+
+// SetClientCertificate enables mutual TLS using the given certificate and key files
+func (obj *grpcTransport) SetClientCertificate(certFile string, keyFile string) GrpcTransport {
+	obj.certFile = certFile
+	obj.keyFile = keyFile
+	return obj
+}
+
+// SetServerName enables TLS and overrides the server name used for SNI and certificate verification
+func (obj *grpcTransport) SetServerName(value string) GrpcTransport {
+	obj.serverName = value
+	return obj
+}
+
+// ServerName returns the server name used for SNI and certificate verification
+func (obj *grpcTransport) ServerName() string {
+	return obj.serverName
+}
+
+// SetRetryPolicy sets the policy used to retry requests failing with a retryable status code,
+// a copy of the policy is kept so that it is not affected by later changes
+func (obj *grpcTransport) SetRetryPolicy(value RetryPolicy) GrpcTransport {
+	obj.retryPolicy = toRetryPolicy(value)
+	return obj
+}
+
+// RetryPolicy returns a copy of the policy used to retry failed requests
+func (obj *grpcTransport) RetryPolicy() RetryPolicy {
+	if obj.retryPolicy == nil {
+		return nil
+	}
+	return toRetryPolicy(obj.retryPolicy)
+}
+
+// SetUploadProgress sets the callback reporting the progress of streamed uploads
+func (obj *grpcTransport) SetUploadProgress(value UploadProgressFunc) GrpcTransport {
+	obj.uploadProgress = value
+	return obj
+}
+
+// SetResumableUploads restarts a failed streamed upload from the offset acknowledged by the server
+func (obj *grpcTransport) SetResumableUploads(maxRestarts int) GrpcTransport {
+	obj.uploadRestarts = maxRestarts
+	return obj
+}
+
+// ResumableUploads returns the maximum number of restarts of a streamed upload
+func (obj *grpcTransport) ResumableUploads() int {
+	return obj.uploadRestarts
+}
+
+// resumeUpload calls upload until it succeeds or fails with a status code that is not retryable,
+// waiting for the backoff of the retry policy before every restart which reuses the same upload id so that the server resumes from the bytes it has received
+func (obj *grpcTransport) resumeUpload(ctx context.Context, operation string, upload func(ctx context.Context, uploadId string) error) error {
+	uploadId := newUploadId()
+	for restart := 0; ; restart++ {
+		err := upload(ctx, uploadId)
+		if err == nil || restart >= obj.uploadRestarts || ctx.Err() != nil {
+			return err
+		}
+		if code := status.Code(err); code != grpcCodes.Unavailable && code != grpcCodes.Aborted && !obj.retryPolicy.retryableCode(code) {
+			return err
+		}
+		delay := obj.retryPolicy.restartBackoff(restart + 1)
+		logs.Debug("resuming failed upload", "Operation", operation, "Restart", restart+1, "Backoff", delay.String(), "Error", err.Error())
+		if !waitFor(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// retry runs attempt according to the retry policy of the transport,
+// an attempt is retried when it fails with one of the retryable grpc status codes
+func (obj *grpcTransport) retry(ctx context.Context, operation string, idempotent bool, attempt func(ctx context.Context) error) error {
+	return obj.retryPolicy.run(ctx, operation, idempotent, func(err error) bool {
+		return obj.retryPolicy.retryableCode(status.Code(err))
+	}, attempt)
+}
+
+// hasTLS returns true when any of the tls options has been set on the transport
+func (obj *grpcTransport) hasTLS() bool {
+	return obj.tlsConfig != nil || obj.caCertFile != "" || obj.certFile != "" || obj.serverName != ""
+}
+
+// transportCredentials returns the credentials used to dial the grpc targets
+func (obj *grpcTransport) transportCredentials() (credentials.TransportCredentials, error) {
+	if !obj.hasTLS() {
+		return insecure.NewCredentials(), nil
+	}
+	config, err := buildTLSConfig(obj.tlsConfig, obj.caCertFile, obj.certFile, obj.keyFile, obj.serverName)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// buildTLSConfig returns a copy of base updated with the CA certificates,
+// client certificate and server name when they are provided
+func buildTLSConfig(base *tls.Config, caCertFile string, certFile string, keyFile string, serverName string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if base != nil {
+		config = base.Clone()
+	}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate file %s: %v", caCertFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid PEM encoded CA certificates found in %s", caCertFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate %s and key %s: %v", certFile, keyFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if serverName != "" {
+		config.ServerName = serverName
+	}
+	return config, nil
+}
+
 type httpTransport struct {
-	location string
-	verify   bool
-	conn     net.Conn
+	endpoints           *endpoints
+	verify              bool
+	verifySet           bool
+	tlsConfig           *tls.Config
+	caCertFile          string
+	certFile            string
+	keyFile             string
+	serverName          string
+	minTLSVersion       uint16
+	retryPolicy         *retryPolicy
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	keepAlive           time.Duration
+	enableHttpStreaming bool
+	chunkSize           uint64
+	uploadProgress      UploadProgressFunc
+	uploadRestarts      int
+	compression         string
+	// loopback dials the in-memory connections of a loopback transport
+	loopback func(ctx context.Context) (net.Conn, error)
 }
 
 type HttpTransport interface {
 	SetLocation(value string) HttpTransport
 	Location() string
+	// SetLocations sets the ordered base urls of redundant servers, a request is sent to the
+	// next url when the active one cannot be reached or answers with 503 Service Unavailable
+	SetLocations(value []string) HttpTransport
+	// Locations get ordered base urls
+	Locations() []string
+	// ActiveLocation get base url which has answered the last request
+	ActiveLocation() string
+	// EnableRoundRobin spreads the requests over the base urls in turn
+	EnableRoundRobin() HttpTransport
+	// DisableRoundRobin sends the requests to the active base url, which is the default
+	DisableRoundRobin() HttpTransport
+	// SetFailoverCooldown sets how long a base url which could not be reached is skipped, it defaults to 30 seconds
+	SetFailoverCooldown(value time.Duration) HttpTransport
+	// FailoverCooldown get how long a base url which could not be reached is skipped
+	FailoverCooldown() time.Duration
 	SetVerify(value bool) HttpTransport
 	Verify() bool
+	// SetTLSConfig sets the tls configuration used for https connections
+	// CA certificate, client certificate, server name and minimum version set on the transport take precedence over it
+	SetTLSConfig(value *tls.Config) HttpTransport
+	// TLSConfig get tls configuration used for https connections
+	TLSConfig() *tls.Config
+	// SetCACertFile verifies the server against the PEM encoded CA certificates in the given file
+	SetCACertFile(value string) HttpTransport
+	// CACertFile get PEM encoded CA certificate file
+	CACertFile() string
+	// SetClientCertificate enables mutual TLS using the PEM encoded certificate and key files
+	SetClientCertificate(certFile string, keyFile string) HttpTransport
+	// SetServerName overrides the server name used for SNI and certificate verification
+	SetServerName(value string) HttpTransport
+	// ServerName get server name used for SNI and certificate verification
+	ServerName() string
+	// SetMinTLSVersion sets the minimum accepted TLS version e.g. tls.VersionTLS12
+	SetMinTLSVersion(value uint16) HttpTransport
+	// MinTLSVersion get minimum accepted TLS version
+	MinTLSVersion() uint16
+	// SetRetryPolicy sets the policy used to retry requests failing with a network error or a retryable status
+	SetRetryPolicy(value RetryPolicy) HttpTransport
+	// RetryPolicy get policy used to retry failed requests
+	RetryPolicy() RetryPolicy
+	// SetMaxIdleConns sets the maximum number of idle connections kept in the pool, zero means no limit
+	SetMaxIdleConns(value int) HttpTransport
+	// MaxIdleConns get maximum number of idle connections kept in the pool
+	MaxIdleConns() int
+	// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept per host
+	SetMaxIdleConnsPerHost(value int) HttpTransport
+	// MaxIdleConnsPerHost get maximum number of idle connections kept per host
+	MaxIdleConnsPerHost() int
+	// SetMaxConnsPerHost limits the number of connections per host including the ones in use, zero means no limit
+	SetMaxConnsPerHost(value int) HttpTransport
+	// MaxConnsPerHost get maximum number of connections per host
+	MaxConnsPerHost() int
+	// SetIdleConnTimeout sets how long an idle connection is kept in the pool, zero means no limit
+	SetIdleConnTimeout(value time.Duration) HttpTransport
+	// IdleConnTimeout get how long an idle connection is kept in the pool
+	IdleConnTimeout() time.Duration
+	// SetKeepAlive sets the interval of the tcp keep-alive probes, a negative value disables them
+	SetKeepAlive(value time.Duration) HttpTransport
+	// KeepAlive get interval of the tcp keep-alive probes
+	KeepAlive() time.Duration
+	// EnableHttpStreaming sends the body of client streaming operations using chunked transfer encoding
+	EnableHttpStreaming() HttpTransport
+	// DisableHttpStreaming sends the body of client streaming operations in a single piece
+	DisableHttpStreaming() HttpTransport
+	// SetStreamChunkBytes sets the maximum size in bytes of the chunks the body is streamed in,
+	// unlike GrpcTransport.SetStreamChunkSize which takes megabytes
+	SetStreamChunkBytes(value uint64) HttpTransport
+	// StreamChunkBytes get maximum size in bytes of the chunks the body is streamed in
+	StreamChunkBytes() uint64
+	// SetUploadProgress sets the callback reporting the progress of streamed uploads
+	SetUploadProgress(value UploadProgressFunc) HttpTransport
+	// SetResumableUploads restarts a streamed upload failing with a network error or a retryable status up to maxRestarts times,
+	// it is resumed from the offset acknowledged by the server which has to support resumable uploads e.g. using an UploadStore
+	SetResumableUploads(maxRestarts int) HttpTransport
+	// ResumableUploads get maximum number of restarts of a streamed upload, zero when uploads are not resumable
+	ResumableUploads() int
+	// SetCompression compresses request bodies and accepts compressed responses using one of
+	// CompressionGzip, CompressionZstd or CompressionNone which is the default
+	SetCompression(value string) HttpTransport
+	// Compression get compression of request bodies
+	Compression() string
 }
 
 // Location
 func (obj *httpTransport) Location() string {
-	logs.Debug("", "Location  ", obj.location)
-	return obj.location
+	location := obj.endpoints.Locations()[0]
+	logs.Debug("", "Location  ", location)
+	return location
 }
 
 // SetLocation
 func (obj *httpTransport) SetLocation(value string) HttpTransport {
-	obj.location = value
+	obj.endpoints.setLocations([]string{value})
+	return obj
+}
+
+// SetLocations sets the ordered base urls of redundant servers
+func (obj *httpTransport) SetLocations(value []string) HttpTransport {
+	if len(value) == 0 {
+		fmt.Println("No location has been provided, so will not be considered")
+		return obj
+	}
+	obj.endpoints.setLocations(value)
+	return obj
+}
+
+// Locations returns the ordered base urls
+func (obj *httpTransport) Locations() []string {
+	return obj.endpoints.Locations()
+}
+
+// ActiveLocation returns the base url which has answered the last request
+func (obj *httpTransport) ActiveLocation() string {
+	return obj.endpoints.activeLocation()
+}
+
+// EnableRoundRobin spreads the requests over the base urls in turn
+func (obj *httpTransport) EnableRoundRobin() HttpTransport {
+	obj.endpoints.setRoundRobin(true)
+	return obj
+}
+
+// DisableRoundRobin sends the requests to the active base url
+func (obj *httpTransport) DisableRoundRobin() HttpTransport {
+	obj.endpoints.setRoundRobin(false)
+	return obj
+}
+
+// SetFailoverCooldown sets how long a base url which could not be reached is skipped
+func (obj *httpTransport) SetFailoverCooldown(value time.Duration) HttpTransport {
+	obj.endpoints.setCooldown(value)
 	return obj
 }
 
+// FailoverCooldown returns how long a base url which could not be reached is skipped
+func (obj *httpTransport) FailoverCooldown() time.Duration {
+	return obj.endpoints.Cooldown()
+}
+
+// waitUntilReady checks the base urls until one of them accepts connections
+func (obj *httpTransport) waitUntilReady(ctx context.Context) error {
+	return waitUntilReady(ctx, obj.endpoints, func(ctx context.Context, location string) error {
+		dial := (&net.Dialer{}).DialContext
+		if obj.loopback != nil {
+			dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				return obj.loopback(ctx)
+			}
+		}
+		address, err := hostPort(location)
+		if err != nil {
+			return err
+		}
+		conn, err := dial(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// hostPort returns the address of the server of a base url
+func hostPort(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	}
+	return net.JoinHostPort(u.Hostname(), "443"), nil
+}
+
+// unreachable tells whether err has been returned because the base url of the request could not be reached,
+// a server answering with 503 Service Unavailable has not processed the request either
+func (obj *httpTransport) unreachable(err error) bool {
+	var coded interface{ Code() int32 }
+	if errors.As(err, &coded) && coded.Code() == http.StatusServiceUnavailable {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
 // Verify returns whether or not TLS certificates will be verified by the server
 func (obj *httpTransport) Verify() bool {
 	return obj.verify
 }
 
 // SetVerify determines whether or not TLS certificates will be verified by the server
+// verification is skipped by default only as long as no tls option is set,
+// certificates are always verified when a CA certificate file or a tls configuration is set
 func (obj *httpTransport) SetVerify(value bool) HttpTransport {
 	obj.verify = value
+	obj.verifySet = true
 	return obj
 }
 
-type apiSt struct {
-	grpc     *grpcTransport
-	http     *httpTransport
-	tracer   Telemetry
-	warnings string
+// SetTLSConfig sets the tls configuration used for https connections
+func (obj *httpTransport) SetTLSConfig(value *tls.Config) HttpTransport {
+	obj.tlsConfig = value
+	return obj
 }
 
-type api interface {
-	Telemetry() Telemetry
-	SetCustomTelemetry(telObj Telemetry)
-	NewGrpcTransport() GrpcTransport
-	hasGrpcTransport() bool
-	NewHttpTransport() HttpTransport
-	hasHttpTransport() bool
-	Close() error
-	// Warnings Api is only for testing purpose
-	// and not intended to use in production
-	getWarnings() string
-	deprecated(message string)
-	under_review(message string)
-	addWarnings(message string)
+// TLSConfig returns the tls configuration used for https connections
+func (obj *httpTransport) TLSConfig() *tls.Config {
+	return obj.tlsConfig
 }
 
-// NewGrpcTransport sets the underlying transport of the Api as grpc
-func (api *apiSt) NewGrpcTransport() GrpcTransport {
-	api.grpc = &grpcTransport{
-		location:            "localhost:5050",
-		requestTimeout:      10 * time.Second,
-		dialTimeout:         10 * time.Second,
-		enableGrpcStreaming: false,
-		chunkSize:           4000000,
-	}
-	api.http = nil
-	return api.grpc
+// SetCACertFile verifies the server against the CA certificates in the given file
+func (obj *httpTransport) SetCACertFile(value string) HttpTransport {
+	obj.caCertFile = value
+	return obj
 }
 
-// HasGrpcTransport will return True for gRPC transport
-func (api *apiSt) hasGrpcTransport() bool {
-	return api.grpc != nil
+// CACertFile returns the CA certificate file
+func (obj *httpTransport) CACertFile() string {
+	return obj.caCertFile
 }
 
-// NewHttpTransport sets the underlying transport of the Api as http
-func (api *apiSt) NewHttpTransport() HttpTransport {
-	api.http = &httpTransport{
-		location: "https://localhost:443",
-		verify:   false,
-	}
-	if api.grpc != nil {
-		if api.grpc.clientConnection != nil {
-			api.grpc.clientConnection.Close()
-		}
-	}
-	api.grpc = nil
-	return api.http
+// SetClientCertificate enables mutual TLS using the given certificate and key files
+func (obj *httpTransport) SetClientCertificate(certFile string, keyFile string) HttpTransport {
+	obj.certFile = certFile
+	obj.keyFile = keyFile
+	return obj
 }
 
-func (api *apiSt) hasHttpTransport() bool {
-	return api.http != nil
+// SetServerName overrides the server name used for SNI and certificate verification
+func (obj *httpTransport) SetServerName(value string) HttpTransport {
+	obj.serverName = value
+	return obj
 }
 
-func (api *apiSt) getWarnings() string {
-	return api.warnings
+// ServerName returns the server name used for SNI and certificate verification
+func (obj *httpTransport) ServerName() string {
+	return obj.serverName
 }
 
-func (api *apiSt) addWarnings(message string) {
-	logs.Warn(message)
-	api.warnings = message
+// SetMinTLSVersion sets the minimum accepted TLS version
+func (obj *httpTransport) SetMinTLSVersion(value uint16) HttpTransport {
+	obj.minTLSVersion = value
+	return obj
 }
 
-func (api *apiSt) deprecated(message string) {
-	api.warnings = message
-	logs.Warn(message)
+// MinTLSVersion returns the minimum accepted TLS version
+func (obj *httpTransport) MinTLSVersion() uint16 {
+	return obj.minTLSVersion
 }
 
-func (api *apiSt) under_review(message string) {
-	api.warnings = message
-	logs.Warn(message)
+// SetRetryPolicy sets the policy used to retry requests failing with a network error or a retryable status,
+// a copy of the policy is kept so that it is not affected by later changes
+func (obj *httpTransport) SetRetryPolicy(value RetryPolicy) HttpTransport {
+	obj.retryPolicy = toRetryPolicy(value)
+	return obj
 }
 
-// Returns instance of telemetry operations
-func (api *apiSt) Telemetry() Telemetry {
-	return api.tracer
+// RetryPolicy returns a copy of the policy used to retry failed requests
+func (obj *httpTransport) RetryPolicy() RetryPolicy {
+	if obj.retryPolicy == nil {
+		return nil
+	}
+	return toRetryPolicy(obj.retryPolicy)
 }
 
-// Returns instance of telemetry operations
-func (api *apiSt) SetCustomTelemetry(telObj Telemetry) {
-	api.tracer = telObj
+// SetMaxIdleConns sets the maximum number of idle connections kept in the pool
+func (obj *httpTransport) SetMaxIdleConns(value int) HttpTransport {
+	obj.maxIdleConns = value
+	return obj
+}
+
+// MaxIdleConns returns the maximum number of idle connections kept in the pool
+func (obj *httpTransport) MaxIdleConns() int {
+	return obj.maxIdleConns
+}
+
+// SetMaxIdleConnsPerHost sets the maximum number of idle connections kept per host
+func (obj *httpTransport) SetMaxIdleConnsPerHost(value int) HttpTransport {
+	obj.maxIdleConnsPerHost = value
+	return obj
+}
+
+// MaxIdleConnsPerHost returns the maximum number of idle connections kept per host
+func (obj *httpTransport) MaxIdleConnsPerHost() int {
+	return obj.maxIdleConnsPerHost
+}
+
+// SetMaxConnsPerHost limits the number of connections per host including the ones in use
+func (obj *httpTransport) SetMaxConnsPerHost(value int) HttpTransport {
+	obj.maxConnsPerHost = value
+	return obj
+}
+
+// MaxConnsPerHost returns the maximum number of connections per host
+func (obj *httpTransport) MaxConnsPerHost() int {
+	return obj.maxConnsPerHost
+}
+
+// SetIdleConnTimeout sets how long an idle connection is kept in the pool
+func (obj *httpTransport) SetIdleConnTimeout(value time.Duration) HttpTransport {
+	obj.idleConnTimeout = value
+	return obj
+}
+
+// IdleConnTimeout returns how long an idle connection is kept in the pool
+func (obj *httpTransport) IdleConnTimeout() time.Duration {
+	return obj.idleConnTimeout
+}
+
+// SetKeepAlive sets the interval of the tcp keep-alive probes
+func (obj *httpTransport) SetKeepAlive(value time.Duration) HttpTransport {
+	obj.keepAlive = value
+	return obj
+}
+
+// KeepAlive returns the interval of the tcp keep-alive probes
+func (obj *httpTransport) KeepAlive() time.Duration {
+	return obj.keepAlive
+}
+
+// EnableHttpStreaming sends the body of client streaming operations using chunked transfer encoding
+func (obj *httpTransport) EnableHttpStreaming() HttpTransport {
+	obj.enableHttpStreaming = true
+	return obj
+}
+
+// DisableHttpStreaming sends the body of client streaming operations in a single piece
+func (obj *httpTransport) DisableHttpStreaming() HttpTransport {
+	obj.enableHttpStreaming = false
+	return obj
+}
+
+// SetStreamChunkBytes sets the maximum size in bytes of the chunks the body is streamed in
+func (obj *httpTransport) SetStreamChunkBytes(value uint64) HttpTransport {
+	obj.chunkSize = value
+	return obj
+}
+
+// StreamChunkBytes returns the maximum size in bytes of the chunks the body is streamed in
+func (obj *httpTransport) StreamChunkBytes() uint64 {
+	return obj.chunkSize
+}
+
+// SetUploadProgress sets the callback reporting the progress of streamed uploads
+func (obj *httpTransport) SetUploadProgress(value UploadProgressFunc) HttpTransport {
+	obj.uploadProgress = value
+	return obj
+}
+
+// SetResumableUploads restarts a failed streamed upload from the offset acknowledged by the server
+func (obj *httpTransport) SetResumableUploads(maxRestarts int) HttpTransport {
+	obj.uploadRestarts = maxRestarts
+	return obj
+}
+
+// ResumableUploads returns the maximum number of restarts of a streamed upload
+func (obj *httpTransport) ResumableUploads() int {
+	return obj.uploadRestarts
+}
+
+// SetCompression compresses request bodies and accepts responses compressed the same way
+func (obj *httpTransport) SetCompression(value string) HttpTransport {
+	if !validCompression(value) {
+		fmt.Printf("The compression %s is not supported, so will not be considered. supported values are %s, %s and %s\n", value, CompressionNone, CompressionGzip, CompressionZstd)
+		return obj
+	}
+	obj.compression = value
+	return obj
+}
+
+// Compression returns the compression of request bodies
+func (obj *httpTransport) Compression() string {
+	return obj.compression
+}
+
+// compressedBody returns the request body compressed with the compression of the transport
+// along with the Content-Encoding header value, the body is returned unchanged without compression
+func (obj *httpTransport) compressedBody(body []byte) ([]byte, string, error) {
+	switch obj.compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(body); err != nil {
+			return nil, "", err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), CompressionGzip, nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(body, nil), CompressionZstd, nil
+	}
+	return body, "", nil
+}
+
+// streamedBody returns a reader yielding body compressed with the compression of the transport,
+// body is copied through a pipe by a goroutine as the returned reader is read so that neither
+// body nor its compressed form is held in memory, the goroutine ends once body has been copied
+// or the returned reader has been closed, which the http client does once a request is sent
+func (obj *httpTransport) streamedBody(body io.Reader) (io.ReadCloser, string) {
+	reader, writer := io.Pipe()
+	var compressor io.WriteCloser
+	contentEncoding := ""
+	switch obj.compression {
+	case CompressionGzip:
+		compressor, contentEncoding = gzip.NewWriter(writer), CompressionGzip
+	case CompressionZstd:
+		encoder, _ := zstd.NewWriter(writer, zstd.WithEncoderConcurrency(1))
+		compressor, contentEncoding = encoder, CompressionZstd
+	}
+	go func() {
+		var err error
+		if compressor == nil {
+			_, err = io.Copy(writer, body)
+		} else {
+			_, err = io.Copy(compressor, body)
+			if closeErr := compressor.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader, contentEncoding
+}
+
+// decompressResponse replaces the compressed body of a response with its decompressed content,
+// responses are only compressed by the server when the request accepted a compression
+func decompressResponse(resp *http.Response) error {
+	var reader io.ReadCloser
+	switch resp.Header.Get("Content-Encoding") {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return err
+		}
+		reader = &decompressedBody{Reader: gz, body: resp.Body}
+	case CompressionZstd:
+		// a single decoder goroutine so that nothing is left running once the body is closed
+		decoder, err := zstd.NewReader(resp.Body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			resp.Body.Close()
+			return err
+		}
+		reader = &decompressedBody{Reader: decoder, body: resp.Body, close: decoder.Close}
+	default:
+		return nil
+	}
+	resp.Body = reader
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type decompressedBody struct {
+	io.Reader
+	body  io.ReadCloser
+	close func()
+}
+
+func (obj *decompressedBody) Close() error {
+	if obj.close != nil {
+		obj.close()
+	}
+	return obj.body.Close()
+}
+
+// compressions supported by the grpc and http transports
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+func validCompression(value string) bool {
+	return value == CompressionNone || value == CompressionGzip || value == CompressionZstd
+}
+
+// zstdEncoder and zstdDecoder are shared by all the transports, whole messages are
+// encoded and decoded at once which is safe for concurrent use
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	// grpc messages are never larger than math.MaxInt32
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(math.MaxInt32))
+)
+
+// zstdCompressor is the grpc compressor registered under CompressionZstd
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return &zstdMessageWriter{writer: w}, nil
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	message, err := zstdDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(message), nil
+}
+
+// zstdMessageWriter buffers a message and writes it compressed once closed
+type zstdMessageWriter struct {
+	writer io.Writer
+	buf    bytes.Buffer
+}
+
+func (obj *zstdMessageWriter) Write(p []byte) (int, error) {
+	return obj.buf.Write(p)
+}
+
+func (obj *zstdMessageWriter) Close() error {
+	_, err := obj.writer.Write(zstdEncoder.EncodeAll(obj.buf.Bytes(), nil))
+	return err
+}
+
+func init() {
+	// servers using this package decompress the messages of its clients
+	if encoding.GetCompressor(CompressionZstd) == nil {
+		encoding.RegisterCompressor(zstdCompressor{})
+	}
+}
+
+// chunkReader limits every read of reader to chunkSize bytes, the body of a streamed
+// request is piped as it is read, at most one chunk per read
+type chunkReader struct {
+	reader    io.Reader
+	chunkSize uint64
+	// progress is reported after every read when set
+	progress  UploadProgressFunc
+	operation string
+	total     uint64
+	sent      uint64
+	chunk     int
+	// ctx carries the measurement counting the chunks read
+	ctx context.Context
+}
+
+func (obj *chunkReader) Read(p []byte) (int, error) {
+	if obj.chunkSize > 0 && uint64(len(p)) > obj.chunkSize {
+		p = p[:obj.chunkSize]
+	}
+	n, err := obj.reader.Read(p)
+	if n > 0 && obj.ctx != nil {
+		measurementFromContext(obj.ctx).chunk(obj.ctx, "sent")
+	}
+	if n > 0 && obj.progress != nil {
+		obj.sent += uint64(n)
+		obj.progress(UploadProgress{Operation: obj.operation, Sent: obj.sent, Total: obj.total, Chunk: obj.chunk})
+		obj.chunk++
+	}
+	return n, err
+}
+
+// UploadProgress describes the progress of a streamed upload after a chunk has been sent
+type UploadProgress struct {
+	// Operation is the name of the api operation uploading the data
+	Operation string
+	// Sent is the number of bytes sent so far including the ones acknowledged before a resume
+	Sent uint64
+	// Total is the number of bytes of the whole upload
+	Total uint64
+	// Chunk is the index of the chunk which has just been sent
+	Chunk int
+}
+
+// UploadProgressFunc is called after every chunk of a streamed upload has been sent
+type UploadProgressFunc func(progress UploadProgress)
+
+// metadata keys and http headers of the resumable upload protocol, the client sends the id of the upload
+// and the server replies with the number of bytes it has already received, over http the client also sends
+// the offset its body starts from and queries the acknowledged offset with a request having no offset
+const (
+	UploadIdMetadataKey     = "x-upload-id"
+	UploadOffsetMetadataKey = "x-upload-offset"
+)
+
+// uploadOffset returns the offset acknowledged by the server in the header values of a resumable upload
+func uploadOffset(values []string, total int) (uint64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	offset, err := strconv.ParseUint(values[0], 10, 64)
+	if err != nil || offset > uint64(total) {
+		return 0, fmt.Errorf("invalid upload offset %s acknowledged by the server", values[0])
+	}
+	return offset, nil
+}
+
+// default limits of an UploadStore
+const (
+	DefaultUploadTTL      = 10 * time.Minute
+	DefaultUploadMaxBytes = 1 << 30
+)
+
+// UploadStore keeps the bytes received by a server for resumable uploads,
+// a client restarting a failed upload with the same id resumes from them.
+// The partial uploads not updated within the ttl of the store are evicted, as are
+// the least recently updated ones once they hold more than the maximum bytes of the store,
+// the upload receiving the chunk which exceeds the maximum bytes is never evicted for it.
+type UploadStore struct {
+	mutex    sync.Mutex
+	uploads  map[string]*storedUpload
+	ttl      time.Duration
+	maxBytes int
+	// size is the number of bytes held by all the uploads
+	size int
+}
+
+type storedUpload struct {
+	data []byte
+	// generation identifies the stream currently receiving the upload
+	generation int
+	updated    time.Time
+}
+
+// NewUploadStore returns an empty UploadStore
+func NewUploadStore() *UploadStore {
+	return &UploadStore{
+		uploads:  map[string]*storedUpload{},
+		ttl:      DefaultUploadTTL,
+		maxBytes: DefaultUploadMaxBytes,
+	}
+}
+
+// SetTTL sets how long a partial upload is kept after it has last been updated, zero keeps it until it completes
+func (obj *UploadStore) SetTTL(value time.Duration) *UploadStore {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.ttl = value
+	return obj
+}
+
+// SetMaxBytes sets the maximum number of bytes held by the partial uploads, zero removes the limit
+func (obj *UploadStore) SetMaxBytes(value int) *UploadStore {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.maxBytes = value
+	return obj
+}
+
+// Discard forgets the bytes received for the upload id, e.g. once the client has given it up
+func (obj *UploadStore) Discard(id string) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.remove(id)
+}
+
+func (obj *UploadStore) remove(id string) {
+	if stored, ok := obj.uploads[id]; ok {
+		obj.size -= len(stored.data)
+		delete(obj.uploads, id)
+	}
+}
+
+// evict removes the expired uploads then the least recently updated ones until the size limit is met,
+// the upload active, which is receiving a chunk, is kept
+func (obj *UploadStore) evict(now time.Time, active string) {
+	for id, stored := range obj.uploads {
+		if id != active && obj.ttl > 0 && now.Sub(stored.updated) > obj.ttl {
+			obj.remove(id)
+		}
+	}
+	for obj.maxBytes > 0 && obj.size > obj.maxBytes {
+		oldest := ""
+		for id, stored := range obj.uploads {
+			if id != active && (oldest == "" || stored.updated.Before(obj.uploads[oldest].updated)) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		obj.remove(oldest)
+	}
+}
+
+// received returns the number of bytes kept for the upload id
+func (obj *UploadStore) received(id string) int {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.evict(time.Now(), "")
+	if stored, ok := obj.uploads[id]; ok {
+		return len(stored.data)
+	}
+	return 0
+}
+
+// open starts receiving the upload id from offset unless offset is not the number of bytes kept for it,
+// which is returned either way, a negative offset resumes the upload from the bytes kept for it
+func (obj *UploadStore) open(id string, offset int64) (*ResumedUpload, int) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	now := time.Now()
+	obj.evict(now, id)
+	stored, ok := obj.uploads[id]
+	received := 0
+	if ok {
+		received = len(stored.data)
+	}
+	if offset >= 0 && offset != int64(received) {
+		return nil, received
+	}
+	if !ok {
+		stored = &storedUpload{}
+		obj.uploads[id] = stored
+	}
+	// a stream of a previous attempt still being torn down cannot append anymore
+	stored.generation++
+	stored.updated = now
+	return &ResumedUpload{store: obj, id: id, generation: stored.generation}, received
+}
+
+// ResumedUpload accumulates the chunks of a client streaming upload received by a server
+type ResumedUpload struct {
+	store      *UploadStore
+	id         string
+	generation int
+	// data holds the chunks of an upload without id, the others are held by the store only
+	data []byte
+}
+
+// Resume starts receiving the upload of stream, if the client has sent an upload id the bytes
+// already received for it are kept and their number is sent to the client as header metadata
+func (obj *UploadStore) Resume(stream grpc.ServerStream) (*ResumedUpload, error) {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	ids := md.Get(UploadIdMetadataKey)
+	if len(ids) == 0 {
+		return &ResumedUpload{store: obj}, nil
+	}
+	upload, offset := obj.open(ids[0], -1)
+	header := metadata.Pairs(UploadOffsetMetadataKey, strconv.Itoa(offset))
+	if err := stream.SendHeader(header); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// ReceiveRequest reads the body of an http request uploading data, it tells whether the request
+// has to be handled, in which case its body is replaced with the whole upload. A request carrying
+// an upload id appends its body to the bytes already received for the id, and is answered here with
+// the number of those bytes in the offset header when it only queries it, when its offset does not
+// match it, when its body could not be read in full or when a newer attempt has taken the upload over.
+func (obj *UploadStore) ReceiveRequest(w http.ResponseWriter, r *http.Request) bool {
+	id := r.Header.Get(UploadIdMetadataKey)
+	if id == "" {
+		return true
+	}
+	value := r.Header.Get(UploadOffsetMetadataKey)
+	if value == "" {
+		w.Header().Set(UploadOffsetMetadataKey, strconv.Itoa(obj.received(id)))
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, fmt.Sprintf("invalid upload offset %s", value), http.StatusBadRequest)
+		return false
+	}
+	upload, received := obj.open(id, offset)
+	if upload == nil {
+		w.Header().Set(UploadOffsetMetadataKey, strconv.Itoa(received))
+		w.WriteHeader(http.StatusConflict)
+		return false
+	}
+	if r.Body != nil {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Body.Read(buf)
+			upload.Append(buf[:n])
+			if err == io.EOF {
+				break
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				// resuming the upload cannot help
+				obj.Discard(id)
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return false
+			}
+			if err != nil {
+				// the bytes received so far are kept for the client to resume the upload
+				w.Header().Set(UploadOffsetMetadataKey, strconv.Itoa(obj.received(id)))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return false
+			}
+		}
+	}
+	data, ok := upload.complete()
+	if !ok {
+		// the upload has been taken over by a newer attempt or evicted meanwhile
+		w.Header().Set(UploadOffsetMetadataKey, strconv.Itoa(obj.received(id)))
+		w.WriteHeader(http.StatusConflict)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return true
+}
+
+// Append records a received chunk, the chunks of an attempt superseded by a newer one are dropped
+func (obj *ResumedUpload) Append(datum []byte) {
+	if obj.id == "" {
+		obj.data = append(obj.data, datum...)
+		return
+	}
+	if len(datum) == 0 {
+		return
+	}
+	obj.store.mutex.Lock()
+	defer obj.store.mutex.Unlock()
+	if stored, ok := obj.store.uploads[obj.id]; ok && stored.generation == obj.generation {
+		stored.data = append(stored.data, datum...)
+		stored.updated = time.Now()
+		obj.store.size += len(datum)
+		obj.store.evict(stored.updated, obj.id)
+	}
+}
+
+// Complete forgets the upload once it has been fully received and returns its bytes,
+// nil is returned for an attempt superseded by a newer one
+func (obj *ResumedUpload) Complete() []byte {
+	data, _ := obj.complete()
+	return data
+}
+
+// complete returns the bytes of the upload and whether they are still held by this attempt
+func (obj *ResumedUpload) complete() ([]byte, bool) {
+	if obj.id == "" {
+		return obj.data, true
+	}
+	obj.store.mutex.Lock()
+	defer obj.store.mutex.Unlock()
+	stored, ok := obj.store.uploads[obj.id]
+	if !ok || stored.generation != obj.generation {
+		return nil, false
+	}
+	obj.store.remove(obj.id)
+	return stored.data, true
+}
+
+// newUploadId returns the id identifying the attempts of a resumable upload
+func newUploadId() string {
+	return fmt.Sprintf("%x-%x", time.Now().UnixNano(), rand.Uint64())
+}
+
+// errRetryableStatus is returned by an http attempt which received a retryable status
+var errRetryableStatus = errors.New("retryable http status")
+
+// errUploadConflict is returned by an attempt of a resumable upload whose offset is not the one acknowledged by the server
+var errUploadConflict = errors.New("upload offset conflict")
+
+// resumeUpload calls upload until it succeeds or fails with an error which is neither a network error, a retryable status
+// nor an offset conflict, upload is told whether it restarts the upload in which case it resumes from the acknowledged offset,
+// every restart waits for the backoff of the retry policy unless ctx is done first
+func (obj *httpTransport) resumeUpload(ctx context.Context, operation string, upload func(ctx context.Context, restart bool) error) error {
+	for restart := 0; ; restart++ {
+		err := upload(ctx, restart > 0)
+		if err == nil || restart >= obj.uploadRestarts || ctx.Err() != nil {
+			return err
+		}
+		var netErr net.Error
+		if err != errRetryableStatus && err != errUploadConflict && !errors.As(err, &netErr) {
+			return err
+		}
+		delay := obj.retryPolicy.restartBackoff(restart + 1)
+		logs.Debug("resuming failed upload", "Operation", operation, "Restart", restart+1, "Backoff", delay.String(), "Error", err.Error())
+		if !waitFor(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// retry runs attempt according to the retry policy of the transport,
+// an attempt is retried when it fails with a network error or returns errRetryableStatus
+func (obj *httpTransport) retry(ctx context.Context, operation string, idempotent bool, attempt func(ctx context.Context) error) error {
+	return obj.retryPolicy.run(ctx, operation, idempotent, func(err error) bool {
+		var netErr net.Error
+		return err == errRetryableStatus || (errors.As(err, &netErr) && ctx.Err() == nil)
+	}, attempt)
+}
+
+// clientTLSConfig returns the tls configuration used to dial https locations
+func (obj *httpTransport) clientTLSConfig() (*tls.Config, error) {
+	config, err := buildTLSConfig(obj.tlsConfig, obj.caCertFile, obj.certFile, obj.keyFile, obj.serverName)
+	if err != nil {
+		return nil, err
+	}
+	if obj.minTLSVersion != 0 {
+		config.MinVersion = obj.minTLSVersion
+	}
+	if !obj.verify && obj.caCertFile == "" && obj.tlsConfig == nil && (obj.verifySet || !obj.hasTLS()) {
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
+
+// hasTLS returns true when any of the tls options has been set on the transport
+func (obj *httpTransport) hasTLS() bool {
+	return obj.tlsConfig != nil || obj.caCertFile != "" || obj.certFile != "" || obj.serverName != "" || obj.minTLSVersion != 0
+}
+
+// RetryPolicy describes how requests failing with a transient error are retried.
+// Only idempotent operations (GET, HEAD, PUT, DELETE and OPTIONS in the spec)
+// are retried unless other operations are explicitly opted in.
+type RetryPolicy interface {
+	// SetMaxAttempts sets the maximum number of attempts including the first one
+	SetMaxAttempts(value int) RetryPolicy
+	// MaxAttempts get maximum number of attempts including the first one
+	MaxAttempts() int
+	// SetInitialBackoff sets the delay before the first retry
+	SetInitialBackoff(value time.Duration) RetryPolicy
+	// InitialBackoff get delay before the first retry
+	InitialBackoff() time.Duration
+	// SetMaxBackoff sets the upper bound of the delay between two attempts
+	SetMaxBackoff(value time.Duration) RetryPolicy
+	// MaxBackoff get upper bound of the delay between two attempts
+	MaxBackoff() time.Duration
+	// SetBackoffMultiplier sets the factor the delay is multiplied with after every retry
+	SetBackoffMultiplier(value float64) RetryPolicy
+	// BackoffMultiplier get factor the delay is multiplied with after every retry
+	BackoffMultiplier() float64
+	// SetJitter sets the fraction, between 0 and 1, by which every delay is randomly increased or decreased
+	SetJitter(value float64) RetryPolicy
+	// Jitter get fraction by which every delay is randomly increased or decreased
+	Jitter() float64
+	// SetRetryableCodes sets the grpc status codes that are retried
+	SetRetryableCodes(value ...grpcCodes.Code) RetryPolicy
+	// RetryableCodes get grpc status codes that are retried
+	RetryableCodes() []grpcCodes.Code
+	// SetRetryableStatuses sets the http status codes that are retried
+	SetRetryableStatuses(value ...int) RetryPolicy
+	// RetryableStatuses get http status codes that are retried
+	RetryableStatuses() []int
+	// SetIdempotentOperations marks additional operations e.g. SetConfig as safe to retry
+	SetIdempotentOperations(value ...string) RetryPolicy
+	// IdempotentOperations get operations explicitly marked as safe to retry
+	IdempotentOperations() []string
+}
+
+type retryPolicy struct {
+	maxAttempts          int
+	initialBackoff       time.Duration
+	maxBackoff           time.Duration
+	backoffMultiplier    float64
+	jitter               float64
+	retryableCodes       []grpcCodes.Code
+	retryableStatuses    []int
+	idempotentOperations []string
+}
+
+// NewRetryPolicy returns a retry policy making up to 3 attempts with an exponential backoff
+// starting at 100ms, retrying grpc Unavailable errors and http 429, 502, 503 and 504 statuses
+func NewRetryPolicy() RetryPolicy {
+	return &retryPolicy{
+		maxAttempts:       3,
+		initialBackoff:    100 * time.Millisecond,
+		maxBackoff:        5 * time.Second,
+		backoffMultiplier: 2,
+		jitter:            0.2,
+		retryableCodes:    []grpcCodes.Code{grpcCodes.Unavailable},
+		retryableStatuses: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// toRetryPolicy returns a copy of any implementation of RetryPolicy, so that changing
+// the policy once it has been set does not affect the calls in flight
+func toRetryPolicy(value RetryPolicy) *retryPolicy {
+	if value == nil {
+		return nil
+	}
+	return &retryPolicy{
+		maxAttempts:          value.MaxAttempts(),
+		initialBackoff:       value.InitialBackoff(),
+		maxBackoff:           value.MaxBackoff(),
+		backoffMultiplier:    value.BackoffMultiplier(),
+		jitter:               value.Jitter(),
+		retryableCodes:       append([]grpcCodes.Code{}, value.RetryableCodes()...),
+		retryableStatuses:    append([]int{}, value.RetryableStatuses()...),
+		idempotentOperations: append([]string{}, value.IdempotentOperations()...),
+	}
+}
+
+func (obj *retryPolicy) SetMaxAttempts(value int) RetryPolicy {
+	obj.maxAttempts = value
+	return obj
+}
+
+func (obj *retryPolicy) MaxAttempts() int {
+	return obj.maxAttempts
+}
+
+func (obj *retryPolicy) SetInitialBackoff(value time.Duration) RetryPolicy {
+	obj.initialBackoff = value
+	return obj
+}
+
+func (obj *retryPolicy) InitialBackoff() time.Duration {
+	return obj.initialBackoff
+}
+
+func (obj *retryPolicy) SetMaxBackoff(value time.Duration) RetryPolicy {
+	obj.maxBackoff = value
+	return obj
+}
+
+func (obj *retryPolicy) MaxBackoff() time.Duration {
+	return obj.maxBackoff
+}
+
+func (obj *retryPolicy) SetBackoffMultiplier(value float64) RetryPolicy {
+	obj.backoffMultiplier = value
+	return obj
+}
+
+func (obj *retryPolicy) BackoffMultiplier() float64 {
+	return obj.backoffMultiplier
+}
+
+func (obj *retryPolicy) SetJitter(value float64) RetryPolicy {
+	obj.jitter = value
+	return obj
+}
+
+func (obj *retryPolicy) Jitter() float64 {
+	return obj.jitter
+}
+
+func (obj *retryPolicy) SetRetryableCodes(value ...grpcCodes.Code) RetryPolicy {
+	obj.retryableCodes = value
+	return obj
+}
+
+func (obj *retryPolicy) RetryableCodes() []grpcCodes.Code {
+	return obj.retryableCodes
+}
+
+func (obj *retryPolicy) SetRetryableStatuses(value ...int) RetryPolicy {
+	obj.retryableStatuses = value
+	return obj
+}
+
+func (obj *retryPolicy) RetryableStatuses() []int {
+	return obj.retryableStatuses
+}
+
+func (obj *retryPolicy) SetIdempotentOperations(value ...string) RetryPolicy {
+	obj.idempotentOperations = value
+	return obj
+}
+
+func (obj *retryPolicy) IdempotentOperations() []string {
+	return obj.idempotentOperations
+}
+
+func (obj *retryPolicy) retryableCode(code grpcCodes.Code) bool {
+	if obj == nil {
+		return false
+	}
+	for _, c := range obj.retryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (obj *retryPolicy) retryableStatus(statusCode int) bool {
+	if obj == nil {
+		return false
+	}
+	for _, s := range obj.retryableStatuses {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (obj *retryPolicy) backoff(retry int) time.Duration {
+	delay := float64(obj.initialBackoff) * math.Pow(obj.backoffMultiplier, float64(retry-1))
+	if obj.maxBackoff > 0 && delay > float64(obj.maxBackoff) {
+		delay = float64(obj.maxBackoff)
+	}
+	delay += delay * obj.jitter * (2*rand.Float64() - 1)
+	return time.Duration(delay)
+}
+
+// run calls attempt until it succeeds, fails with an error that is not retryable,
+// the attempts are exhausted or ctx is done; every attempt is recorded as an event
+// of the span in ctx and the error of the last attempt is returned
+func (obj *retryPolicy) run(ctx context.Context, operation string, idempotent bool, retryable func(err error) bool, attempt func(ctx context.Context) error) error {
+	if obj == nil {
+		return attempt(ctx)
+	}
+	idempotent = idempotent || contains(obj.idempotentOperations, operation)
+	span := trace.SpanFromContext(ctx)
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		attrs := []attribute.KeyValue{attribute.Int("attempt", n)}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		span.AddEvent(operation+" attempt", trace.WithAttributes(attrs...))
+		if err == nil || !idempotent || n >= obj.maxAttempts || !retryable(err) {
+			return err
+		}
+		delay := obj.backoff(n)
+		logs.Debug("retrying failed attempt", "Operation", operation, "Attempt", n, "Backoff", delay.String(), "Error", err.Error())
+		if !waitFor(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// restartBackoff returns the delay before the given restart of a resumable upload, starting at 1,
+// which is the backoff of the retry policy or of the default one when none is set
+func (obj *retryPolicy) restartBackoff(restart int) time.Duration {
+	if obj == nil {
+		return NewRetryPolicy().(*retryPolicy).backoff(restart)
+	}
+	return obj.backoff(restart)
+}
+
+// waitFor waits for delay and returns false when ctx is done first
+func waitFor(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type apiSt struct {
+	grpc         *grpcTransport
+	http         *httpTransport
+	tracer       Telemetry
+	warnings     string
+	auth         Authenticator
+	security     map[string][]string
+	idempotent   map[string]bool
+	interceptors []Interceptor
+	cassette     cassetteCodec
+	recorder     *cassetteRecorder
+	replay       *replayTransport
+	dryRun       *dryRunTransport
+	wire         map[string]wireOperation
+	loopback     *loopbackServer
+	// locationFailed is called with every location of the transport which could not be reached
+	locationFailed func(location string)
+	// fake is set for the Api of a FakeApi whose operations are never sent
+	fake bool
+	// mutex guards the lazily established connections, the warnings, the recorder and the interceptors
+	mutex sync.Mutex
+}
+
+type api interface {
+	Telemetry() Telemetry
+	SetCustomTelemetry(telObj Telemetry)
+	NewGrpcTransport() GrpcTransport
+	hasGrpcTransport() bool
+	NewHttpTransport() HttpTransport
+	hasHttpTransport() bool
+	// SetAuthenticator sets the authenticator supplying the credentials of every request
+	SetAuthenticator(value Authenticator)
+	// Authenticator returns the authenticator supplying the credentials of every request
+	Authenticator() Authenticator
+	// Use appends interceptors to the chain invoked around every operation of the Api,
+	// interceptors are called in the order they have been added
+	Use(interceptors ...Interceptor)
+	// StartRecording records every call of an operation along with its response or error
+	// in the cassette file at path, an existing file is overwritten
+	StartRecording(path string) error
+	// StopRecording stops recording and closes the cassette file
+	StopRecording() error
+	// NewReplayTransport sets the underlying transport of the Api as the replay of the cassette file at path,
+	// the calls of the operations are answered with the recorded responses instead of being sent
+	NewReplayTransport(path string) (ReplayTransport, error)
+	hasReplayTransport() bool
+	// NewLoopbackTransport connects the Api in process to a grpc server or an http handler,
+	// the requests go through in-memory connections instead of sockets
+	NewLoopbackTransport() LoopbackTransport
+	closeLoopback()
+	// NewDryRunTransport sets the underlying transport of the Api as a dry run, the requests are validated
+	// and encoded as the grpc or the http transport would send them, then collected instead of being sent
+	NewDryRunTransport() DryRunTransport
+	// WaitUntilReady blocks until a location of the transport is ready to serve requests or ctx is done,
+	// a grpc location is checked using the grpc health protocol and an http one has to accept connections
+	WaitUntilReady(ctx context.Context) error
+	Close() error
+	// Warnings Api is only for testing purpose
+	// and not intended to use in production
+	getWarnings() string
+	deprecated(message string)
+	under_review(message string)
+	addWarnings(message string)
+}
+
+// NewGrpcTransport sets the underlying transport of the Api as grpc
+func (api *apiSt) NewGrpcTransport() GrpcTransport {
+	api.grpc = &grpcTransport{
+		endpoints:           newEndpoints("localhost:5050"),
+		requestTimeout:      10 * time.Second,
+		dialTimeout:         10 * time.Second,
+		enableGrpcStreaming: false,
+		chunkSize:           4000000,
+		maxSendMsgSize:      4 * 1024 * 1024,
+		maxRecvMsgSize:      4 * 1024 * 1024,
+		compression:         CompressionNone,
+		reconnectTimeout:    time.Second,
+	}
+	api.http = nil
+	api.replay = nil
+	api.dryRun = nil
+	return api.grpc
+}
+
+// HasGrpcTransport will return True for gRPC transport
+func (api *apiSt) hasGrpcTransport() bool {
+	return api.grpc != nil
+}
+
+// NewHttpTransport sets the underlying transport of the Api as http
+func (api *apiSt) NewHttpTransport() HttpTransport {
+	api.http = &httpTransport{
+		endpoints:           newEndpoints("https://localhost:443"),
+		verify:              false,
+		maxIdleConns:        100,
+		maxIdleConnsPerHost: 10,
+		idleConnTimeout:     90 * time.Second,
+		keepAlive:           30 * time.Second,
+		chunkSize:           4000000,
+		compression:         CompressionNone,
+	}
+	api.closeGrpcConnections()
+	api.grpc = nil
+	api.replay = nil
+	api.dryRun = nil
+	return api.http
+}
+
+func (api *apiSt) hasHttpTransport() bool {
+	return api.http != nil
+}
+
+// closeGrpcConnections closes the connections of the grpc transport if any
+func (api *apiSt) closeGrpcConnections() {
+	if api.grpc == nil {
+		return
+	}
+	if api.grpc.clientConnection != nil {
+		api.grpc.clientConnection.Close()
+	}
+	if api.grpc.connections != nil {
+		_ = api.grpc.connections.close()
+	}
+}
+
+func (api *apiSt) getWarnings() string {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	return api.warnings
+}
+
+func (api *apiSt) setWarnings(message string) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.warnings = message
+}
+
+func (api *apiSt) addWarnings(message string) {
+	logs.Warn(message)
+	api.setWarnings(message)
+}
+
+func (api *apiSt) deprecated(message string) {
+	api.setWarnings(message)
+	logs.Warn(message)
+}
+
+func (api *apiSt) under_review(message string) {
+	api.setWarnings(message)
+	logs.Warn(message)
+}
+
+// Returns instance of telemetry operations
+func (api *apiSt) Telemetry() Telemetry {
+	return api.tracer
+}
+
+// Returns instance of telemetry operations
+func (api *apiSt) SetCustomTelemetry(telObj Telemetry) {
+	api.tracer = telObj
+}
+
+// SetAuthenticator sets the authenticator supplying the credentials of every request,
+// the credentials are sent as http headers or grpc metadata depending on the transport
+func (api *apiSt) SetAuthenticator(value Authenticator) {
+	api.auth = value
+}
+
+// Authenticator returns the authenticator supplying the credentials of every request
+func (api *apiSt) Authenticator() Authenticator {
+	return api.auth
+}
+
+// credentials returns the credentials to be sent with a request of the given operation
+func (api *apiSt) credentials(ctx context.Context, operation string) (map[string]string, error) {
+	if api.auth == nil {
+		return nil, nil
+	}
+	schemes, found := api.security[operation]
+	if found && len(schemes) == 0 {
+		// the operation is declared without security, no authenticator is asked for credentials
+		return nil, nil
+	}
+	if bound, ok := api.auth.(*schemeAuthenticator); ok && found && !contains(schemes, bound.scheme) {
+		return nil, nil
+	}
+	creds, err := api.auth.Credentials(ctx, operation)
+	if err != nil {
+		return nil, fmt.Errorf("could not get credentials for %s: %v", operation, err)
+	}
+	return creds, nil
+}
+
+// setHttpCredentials adds the credentials of the given operation to the request headers
+func (api *apiSt) setHttpCredentials(ctx context.Context, operation string, req *http.Request) error {
+	creds, err := api.credentials(ctx, operation)
+	if err != nil {
+		return err
+	}
+	for key, value := range creds {
+		req.Header.Set(key, value)
+	}
+	return nil
+}
+
+// grpcCredentialsContext returns ctx carrying the credentials of the given operation as outgoing grpc metadata
+func (api *apiSt) grpcCredentialsContext(ctx context.Context, operation string) (context.Context, error) {
+	creds, err := api.credentials(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range creds {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(key), value)
+	}
+	return ctx, nil
+}
+
+// Invocation describes a single call of an Api operation as seen by interceptors
+type Invocation struct {
+	// Operation is the name of the Api method e.g. SetConfig
+	Operation string
+	// Request is the request object of the operation,
+	// []byte for binary requests and nil for operations without a request body.
+	// An interceptor may replace it with another request of the same type before calling next,
+	// the request sent is the one of the invocation reaching the end of the chain
+	Request interface{}
+	// Transport is the transport used to send the request, either grpc or http
+	Transport string
+}
+
+// Invoker invokes the remaining interceptors of the chain and eventually sends the request,
+// the returned response has the type returned by the Api method of the operation
+type Invoker func(ctx context.Context, invocation *Invocation) (interface{}, error)
+
+// Interceptor is called around every invocation of an Api operation.
+// It may inspect or modify the context and the invocation before calling next,
+// inspect or replace the response and error returned by next,
+// or short-circuit the invocation by returning without calling next.
+type Interceptor func(ctx context.Context, invocation *Invocation, next Invoker) (interface{}, error)
+
+// Use appends interceptors to the chain invoked around every operation of the Api
+func (api *apiSt) Use(interceptors ...Interceptor) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	// the chain is copied on write so that the calls in flight keep the one they started with
+	api.interceptors = append(append([]Interceptor{}, api.interceptors...), interceptors...)
+}
+
+func (api *apiSt) currentInterceptors() []Interceptor {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	return api.interceptors
+}
+
+func (api *apiSt) transportName() string {
+	if api.hasReplayTransport() {
+		return "replay"
+	}
+	if api.dryRun != nil {
+		return "dry-run"
+	}
+	if api.fake {
+		return "fake"
+	}
+	if api.hasHttpTransport() {
+		return "http"
+	}
+	return "grpc"
+}
+
+// invoke runs the interceptor chain of the given operation within a client span,
+// send performs the actual request of the invocation once every interceptor has called its next invoker
+func (api *apiSt) invoke(ctx context.Context, operation string, request interface{}, send func(ctx context.Context, request interface{}) (interface{}, error)) (interface{}, error) {
+	newCtx, span := api.Telemetry().NewSpan(ctx, operation, trace.WithSpanKind(trace.SpanKindClient))
+	defer api.Telemetry().CloseSpan(span)
+	if newCtx != nil {
+		ctx = newCtx
+	}
+
+	if api.replay != nil {
+		// the recorded response is served instead of sending the request
+		send = func(ctx context.Context, request interface{}) (interface{}, error) {
+			return api.replay.serve(ctx, api.cassette, operation, request)
+		}
+	} else if api.dryRun != nil {
+		// the request is collected instead of being sent
+		send = func(ctx context.Context, request interface{}) (interface{}, error) {
+			return api.dryRun.collect(api.wire, operation, request)
+		}
+	} else if endpoints, unreachable := api.transportEndpoints(); endpoints != nil {
+		// a request which cannot reach its location is sent to the next one
+		sendTo := send
+		send = func(ctx context.Context, request interface{}) (interface{}, error) {
+			return endpoints.send(ctx, api.idempotent[operation], func(ctx context.Context) (interface{}, error) {
+				return sendTo(ctx, request)
+			}, unreachable, api.locationFailed)
+		}
+	}
+	// sent is the request of the invocation reaching the end of the chain which is the one captured and recorded
+	sent := request
+	capture := api.Telemetry().capturesPayloads(span)
+	next := Invoker(func(ctx context.Context, invocation *Invocation) (interface{}, error) {
+		sent = invocation.Request
+		if capture {
+			api.capturePayload(span, "REQUEST", func() (json.RawMessage, error) {
+				return api.cassette.encodeRequest(operation, sent)
+			})
+		}
+		return send(ctx, sent)
+	})
+	interceptors := api.currentInterceptors()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func(ctx context.Context, invocation *Invocation) (interface{}, error) {
+			return interceptor(ctx, invocation, inner)
+		}
+	}
+
+	invocation := &Invocation{Operation: operation, Request: request, Transport: api.transportName()}
+	var measurement *callMeasurement
+	if metrics := api.Telemetry().clientMetrics(); metrics != nil {
+		ctx, measurement = metrics.startCall(ctx, operation, invocation.Transport)
+	}
+	start := time.Now()
+	resp, err := next(ctx, invocation)
+	measurement.end(ctx, time.Since(start), err)
+	if err != nil {
+		api.Telemetry().SetSpanStatus(span, codes.Error, err.Error())
+	}
+	if _, isStream := resp.(io.ReadCloser); capture && err == nil && resp != nil && !isStream {
+		api.capturePayload(span, "RESPONSE", func() (json.RawMessage, error) {
+			return api.cassette.encodeResponse(operation, resp)
+		})
+	}
+	if recorder := api.currentRecorder(); recorder != nil {
+		resp = recorder.record(api.cassette, invocation.Transport, operation, sent, start, resp, err)
+	}
+	return resp, err
+}
+
+// capturePayload adds the payload returned by encode as the event name of span
+func (api *apiSt) capturePayload(span trace.Span, name string, encode func() (json.RawMessage, error)) {
+	payload, err := encode()
+	if err != nil {
+		logs.Warn("failed to capture payload", "Event", name, "Error", err.Error())
+		return
+	}
+	if len(payload) > 0 {
+		api.Telemetry().addPayloadEvent(span, name, payload)
+	}
+}
+
+// validationFailed counts a request of operation which has not been sent as it is invalid
+func (api *apiSt) validationFailed(ctx context.Context, operation string) {
+	if metrics := api.Telemetry().clientMetrics(); metrics != nil {
+		metrics.validationFailed(ctx, operation, api.transportName())
+	}
+}
+
+// measureHttpResponse records the size of the request body of the call carried by ctx along with
+// the status of its response, the size of the response body is recorded once it has been read
+func measureHttpResponse(ctx context.Context, requestSize int, response *http.Response) {
+	measurement := measurementFromContext(ctx)
+	if measurement == nil {
+		return
+	}
+	measurement.requestSent(ctx, requestSize)
+	measurement.setHttpStatus(response.StatusCode)
+	response.Body = &measuredBody{ReadCloser: response.Body, ctx: ctx, measurement: measurement}
+}
+
+// transportEndpoints returns the locations of the transport along with the function telling whether an error
+// has been returned because a location could not be reached, nil when requests are not sent to a location
+func (api *apiSt) transportEndpoints() (*endpoints, func(error) bool) {
+	if api.fake || api.replay != nil || api.dryRun != nil {
+		return nil, nil
+	}
+	if api.http != nil {
+		return api.http.endpoints, api.http.unreachable
+	}
+	// a connection set by the user is used as is
+	if api.grpc != nil && api.grpc.clientConnection == nil {
+		return api.grpc.endpoints, api.grpc.unreachable
+	}
+	return nil, nil
+}
+
+// locationContext returns ctx carrying the location its request is sent to, which is the active location
+// of the transport outside of a request, the location is empty when requests are not sent to a location
+func (api *apiSt) locationContext(ctx context.Context) (context.Context, string) {
+	endpoints, _ := api.transportEndpoints()
+	if endpoints == nil {
+		return ctx, ""
+	}
+	if location, ok := ctx.Value(locationContextKey{}).(string); ok {
+		return ctx, location
+	}
+	location := endpoints.activeLocation()
+	return context.WithValue(ctx, locationContextKey{}, location), location
+}
+
+// locationContextKey is the context key of the location a request is sent to
+type locationContextKey struct{}
+
+// endpoints holds the ordered locations of a transport along with their health. The requests are sent to
+// the active location, which is the first one until it cannot be reached, or with round-robin to every location
+// in turn. A location which could not be reached is skipped for the cooldown unless no other location is left.
+type endpoints struct {
+	mutex      sync.Mutex
+	locations  []string
+	roundRobin bool
+	cooldown   time.Duration
+	// active is the index of the location which has answered the last request
+	active int
+	// next is the index of the location the next request is sent to with round-robin
+	next int
+	// failed holds when the locations which could not be reached have failed
+	failed map[string]time.Time
+}
+
+func newEndpoints(location string) *endpoints {
+	return &endpoints{
+		locations: []string{location},
+		cooldown:  30 * time.Second,
+		failed:    map[string]time.Time{},
+	}
+}
+
+func (obj *endpoints) setLocations(locations []string) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.locations = append([]string{}, locations...)
+	obj.active = 0
+	obj.next = 0
+	obj.failed = map[string]time.Time{}
+}
+
+func (obj *endpoints) Locations() []string {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return append([]string{}, obj.locations...)
+}
+
+func (obj *endpoints) activeLocation() string {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return obj.locations[obj.active]
+}
+
+func (obj *endpoints) setRoundRobin(value bool) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.roundRobin = value
+}
+
+func (obj *endpoints) setCooldown(value time.Duration) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.cooldown = value
+}
+
+func (obj *endpoints) Cooldown() time.Duration {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return obj.cooldown
+}
+
+// requestLocation returns the location the request of ctx is sent to, the active location outside of a request
+func (obj *endpoints) requestLocation(ctx context.Context) string {
+	if location, ok := ctx.Value(locationContextKey{}).(string); ok {
+		return location
+	}
+	return obj.activeLocation()
+}
+
+// attemptOrder returns the locations a request is sent to until one can be reached, starting from the active
+// location or with round-robin from the one following the location of the previous request,
+// the locations which have failed within the cooldown come last
+func (obj *endpoints) attemptOrder() []string {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	start := obj.active
+	if obj.roundRobin {
+		start = obj.next
+		obj.next = (obj.next + 1) % len(obj.locations)
+	}
+	var healthy, failed []string
+	for i := range obj.locations {
+		location := obj.locations[(start+i)%len(obj.locations)]
+		if failedAt, ok := obj.failed[location]; ok && time.Since(failedAt) < obj.cooldown {
+			failed = append(failed, location)
+		} else {
+			healthy = append(healthy, location)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+// reached makes location the active location
+func (obj *endpoints) reached(location string) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	delete(obj.failed, location)
+	for i, value := range obj.locations {
+		if value == location {
+			obj.active = i
+			return
+		}
+	}
+}
+
+func (obj *endpoints) fail(location string) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.failed[location] = time.Now()
+}
+
+// send sends a request to its locations in turn until one of them can be reached,
+// failed is called with every location which could not be reached. A request which is not
+// idempotent is only sent to the next location when it has not been sent at all, i.e. the location
+// could not be dialed or its connection was already down, as the server may have processed it.
+func (obj *endpoints) send(ctx context.Context, idempotent bool, send func(ctx context.Context) (interface{}, error), unreachable func(error) bool, failed func(location string)) (interface{}, error) {
+	if _, ok := ctx.Value(locationContextKey{}).(string); ok {
+		// a request made while sending another one e.g. to check the version goes to the same location
+		return send(ctx)
+	}
+	var resp interface{}
+	var err error
+	for _, location := range obj.attemptOrder() {
+		attempt := &delivery{}
+		resp, err = send(attempt.context(context.WithValue(ctx, locationContextKey{}, location)))
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil || !unreachable(err) {
+			obj.reached(location)
+			return resp, err
+		}
+		obj.fail(location)
+		if failed != nil {
+			failed(location)
+		}
+		if !idempotent && attempt.wasSent() {
+			logs.Debug("request not sent again as it may have been processed", "Location", location, "Error", err.Error())
+			return resp, err
+		}
+		logs.Debug("location could not be reached", "Location", location, "Error", err.Error())
+	}
+	return resp, err
+}
+
+// deliveryContextKey is the context key of the delivery of a request to a location
+type deliveryContextKey struct{}
+
+// delivery records whether a request has been written to the connection of its location,
+// a request which has not been cannot have reached the server
+type delivery struct {
+	sent int32
+}
+
+// context returns ctx carrying the delivery, the http requests mark it once their headers are written
+func (obj *delivery) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, deliveryContextKey{}, obj)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			atomic.StoreInt32(&obj.sent, 1)
+		},
+	})
+}
+
+func (obj *delivery) wasSent() bool {
+	return atomic.LoadInt32(&obj.sent) == 1
+}
+
+// grpcDeliveryHandler marks the delivery of the grpc calls whose headers are sent to the server
+type grpcDeliveryHandler struct{}
+
+func (grpcDeliveryHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcDeliveryHandler) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {
+	if _, ok := rpcStats.(*stats.OutHeader); !ok {
+		return
+	}
+	if attempt, ok := ctx.Value(deliveryContextKey{}).(*delivery); ok {
+		atomic.StoreInt32(&attempt.sent, 1)
+	}
+}
+
+func (grpcDeliveryHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcDeliveryHandler) HandleConn(ctx context.Context, connStats stats.ConnStats) {}
+
+// grpcConnections is the client connection of a grpc transport whose connections are dialed by the Api,
+// a connection is dialed to every grpc target and each call goes through the one of its request
+type grpcConnections struct {
+	mutex     sync.Mutex
+	transport *grpcTransport
+	endpoints *endpoints
+	dial      func(location string) (*grpc.ClientConn, error)
+	conns     map[string]*grpc.ClientConn
+}
+
+func newGrpcConnections(transport *grpcTransport, dial func(location string) (*grpc.ClientConn, error)) *grpcConnections {
+	return &grpcConnections{transport: transport, endpoints: transport.endpoints, dial: dial, conns: map[string]*grpc.ClientConn{}}
+}
+
+// conn returns the connection to the grpc target of the request of ctx, dialing it the first time
+func (obj *grpcConnections) conn(ctx context.Context) (*grpc.ClientConn, error) {
+	location := obj.endpoints.requestLocation(ctx)
+	obj.mutex.Lock()
+	conn, ok := obj.conns[location]
+	obj.mutex.Unlock()
+	if ok {
+		return conn, nil
+	}
+	// the lock is not held while dialing as a blocking dial of an unreachable target takes until the dial timeout
+	conn, err := obj.dial(location)
+	if err != nil {
+		return nil, err
+	}
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	if existing, ok := obj.conns[location]; ok {
+		conn.Close()
+		return existing, nil
+	}
+	obj.conns[location] = conn
+	return conn, nil
+}
+
+// active returns the connection to the active grpc target if it has been dialed
+func (obj *grpcConnections) active() *grpc.ClientConn {
+	location := obj.endpoints.activeLocation()
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return obj.conns[location]
+}
+
+func (obj *grpcConnections) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	conn, err := obj.conn(ctx)
+	if err != nil {
+		return err
+	}
+	state := conn.GetState()
+	err = conn.Invoke(ctx, method, args, reply, opts...)
+	if reconnect(ctx, conn, state, err, obj.transport.reconnectTimeout) {
+		err = conn.Invoke(ctx, method, args, reply, opts...)
+	}
+	return err
+}
+
+func (obj *grpcConnections) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	conn, err := obj.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := conn.GetState()
+	stream, err := conn.NewStream(ctx, desc, method, opts...)
+	if reconnect(ctx, conn, state, err, obj.transport.reconnectTimeout) {
+		stream, err = conn.NewStream(ctx, desc, method, opts...)
+	}
+	return stream, err
+}
+
+// reconnect re-establishes a connection once a call has failed with Unavailable because the connection is down,
+// e.g. after a server restart, instead of waiting for the backoff of the connection. It tells whether the call should
+// be sent again, which is the case once the connection is ready within timeout: the call has failed fast without
+// being sent as the connection was already down.
+func reconnect(ctx context.Context, conn *grpc.ClientConn, stateBefore connectivity.State, err error, timeout time.Duration) bool {
+	if status.Code(err) != grpcCodes.Unavailable || conn.GetState() == connectivity.Ready {
+		// the server itself is unavailable
+		return false
+	}
+	conn.ResetConnectBackoff()
+	if stateBefore != connectivity.TransientFailure || timeout <= 0 {
+		// the call may have reached the server before the connection broke
+		// or has already waited for an attempt to connect which failed
+		return false
+	}
+	ctx, cancelFunc := context.WithTimeout(ctx, timeout)
+	defer cancelFunc()
+	interval := readyPollInterval
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.Shutdown || ctx.Err() != nil {
+			return false
+		}
+		pollCtx, cancelPoll := context.WithTimeout(ctx, interval)
+		changed := conn.WaitForStateChange(pollCtx, state)
+		cancelPoll()
+		if !changed {
+			// an attempt to connect made before the server was back has failed,
+			// the next one is made without waiting for the backoff while the
+			// interval between two resets doubles not to flood the server
+			conn.ResetConnectBackoff()
+			interval *= 2
+		}
+	}
+	return true
+}
+
+func (obj *grpcConnections) close() error {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	var closeErr error
+	for location, conn := range obj.conns {
+		if err := conn.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+		delete(obj.conns, location)
+	}
+	return closeErr
+}
+
+// Authenticator supplies the credentials sent along with the requests of an Api.
+// The returned keys are used as http header names or as grpc metadata keys.
+// It is not called for the operations declared without security in the spec.
+type Authenticator interface {
+	Credentials(ctx context.Context, operation string) (map[string]string, error)
+}
+
+// AuthenticatorFunc is an adapter to use an ordinary function as an Authenticator
+// e.g. to compute per operation credentials
+type AuthenticatorFunc func(ctx context.Context, operation string) (map[string]string, error)
+
+// Credentials calls f(ctx, operation)
+func (f AuthenticatorFunc) Credentials(ctx context.Context, operation string) (map[string]string, error) {
+	return f(ctx, operation)
+}
+
+// TokenSource is a callback returning a fresh token along with its expiry time,
+// a zero expiry time means that the token never expires
+type TokenSource func(ctx context.Context) (string, time.Time, error)
+
+// NewBearerAuthenticator returns an Authenticator sending the static token
+// in the Authorization header using the Bearer scheme
+func NewBearerAuthenticator(token string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, operation string) (map[string]string, error) {
+		return map[string]string{"Authorization": "Bearer " + token}, nil
+	})
+}
+
+// NewBasicAuthenticator returns an Authenticator sending the username and password
+// in the Authorization header using the Basic scheme
+func NewBasicAuthenticator(username string, password string) Authenticator {
+	encoded := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return AuthenticatorFunc(func(ctx context.Context, operation string) (map[string]string, error) {
+		return map[string]string{"Authorization": "Basic " + encoded}, nil
+	})
+}
+
+// NewApiKeyAuthenticator returns an Authenticator sending the api key in the given header
+func NewApiKeyAuthenticator(header string, value string) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, operation string) (map[string]string, error) {
+		return map[string]string{header: value}, nil
+	})
+}
+
+// NewTokenAuthenticator returns an Authenticator sending the token obtained from source
+// in the Authorization header using the Bearer scheme.
+// The token is cached and source is called again only once the token has expired.
+func NewTokenAuthenticator(source TokenSource) Authenticator {
+	return &tokenAuthenticator{source: source}
+}
+
+type tokenAuthenticator struct {
+	mutex  sync.Mutex
+	source TokenSource
+	token  string
+	expiry time.Time
+}
+
+func (obj *tokenAuthenticator) Credentials(ctx context.Context, operation string) (map[string]string, error) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	if obj.token == "" || (!obj.expiry.IsZero() && !time.Now().Before(obj.expiry)) {
+		token, expiry, err := obj.source(ctx)
+		if err != nil {
+			return nil, err
+		}
+		obj.token = token
+		obj.expiry = expiry
+	}
+	return map[string]string{"Authorization": "Bearer " + obj.token}, nil
+}
+
+// schemeAuthenticator binds an Authenticator to a security scheme of the spec,
+// its credentials are only sent for operations accepting that scheme
+type schemeAuthenticator struct {
+	Authenticator
+	scheme string
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// errStreamClosed is returned when reading from a stream after it has been closed
+var errStreamClosed = errors.New("read from closed stream")
+
+// streamReader is the reader of a server streaming operation, a chunk is only
+// received from the server once the previous one has been fully read so that
+// a slow reader slows down the server through the flow control of the transport
+type streamReader struct {
+	recv   func() ([]byte, error)
+	cancel context.CancelFunc
+	// mapError converts the errors of the transport into the errors of the api
+	mapError func(err error) error
+	chunk    []byte
+	err      error
+}
+
+func newStreamReader(recv func() ([]byte, error), cancel context.CancelFunc) *streamReader {
+	return &streamReader{recv: recv, cancel: cancel}
+}
+
+func (obj *streamReader) Read(p []byte) (int, error) {
+	for len(obj.chunk) == 0 {
+		if obj.err != nil {
+			return 0, obj.err
+		}
+		chunk, err := obj.recv()
+		if err != nil {
+			if err != io.EOF && obj.mapError != nil {
+				err = obj.mapError(err)
+			}
+			obj.err = err
+			obj.cancel()
+		}
+		obj.chunk = chunk
+	}
+	n := copy(p, obj.chunk)
+	obj.chunk = obj.chunk[n:]
+	return n, nil
+}
+
+// Close aborts the stream if it has not been fully read
+func (obj *streamReader) Close() error {
+	obj.cancel()
+	obj.chunk = nil
+	if obj.err == nil {
+		obj.err = errStreamClosed
+	}
+	return nil
+}
+
+// CassetteEntry is a call of an Api operation recorded in a cassette file,
+// a cassette file holds one JSON encoded entry per line in the order the calls have returned
+type CassetteEntry struct {
+	// Operation is the name of the Api method e.g. SetConfig
+	Operation string `json:"operation"`
+	// Transport is the transport the call has been sent with
+	Transport string `json:"transport"`
+	// Request is the JSON of the request object, a base64 string for binary requests
+	// and empty for operations without a request body
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response is the JSON of the response object, a base64 string for binary responses
+	// and the streams read by the readers of server streaming operations
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error is set when the call has failed
+	Error *CassetteError `json:"error,omitempty"`
+	// Start is the time the call has been made at
+	Start time.Time `json:"start"`
+	// Duration is the time the call has taken in nanoseconds
+	Duration time.Duration `json:"duration"`
+}
+
+// CassetteError is the error returned by a recorded call
+type CassetteError struct {
+	Message string `json:"message"`
+	// Error is the JSON of the Error returned by the server, empty for any other error
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// operationCodec converts the request and the response of an operation to and from JSON
+type operationCodec struct {
+	encodeRequest  func(request interface{}) (json.RawMessage, error)
+	encodeResponse func(response interface{}) (json.RawMessage, error)
+	decodeResponse func(data json.RawMessage) (interface{}, error)
+}
+
+// cassetteCodec converts the calls of the operations of an Api to and from cassette entries
+type cassetteCodec struct {
+	operations  map[string]operationCodec
+	encodeError func(err error) *CassetteError
+	decodeError func(cassetteErr *CassetteError) error
+}
+
+func (obj cassetteCodec) encodeRequest(operation string, request interface{}) (json.RawMessage, error) {
+	codec, ok := obj.operations[operation]
+	if !ok {
+		return nil, fmt.Errorf("operation %s cannot be recorded", operation)
+	}
+	if request == nil || codec.encodeRequest == nil {
+		return nil, nil
+	}
+	return codec.encodeRequest(request)
+}
+
+func (obj cassetteCodec) encodeResponse(operation string, response interface{}) (json.RawMessage, error) {
+	codec, ok := obj.operations[operation]
+	if !ok || codec.encodeResponse == nil {
+		return nil, fmt.Errorf("operation %s cannot be recorded", operation)
+	}
+	return codec.encodeResponse(response)
+}
+
+// canonicalJson returns the compact JSON of value with sorted keys so that
+// the requests recorded and replayed by different builds can be compared
+func canonicalJson(value string, err error) (json.RawMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+func encodeCassetteBytes(value interface{}) (json.RawMessage, error) {
+	return json.Marshal(value.([]byte))
+}
+
+func decodeCassetteBytes(data json.RawMessage) (interface{}, error) {
+	var value []byte
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+func decodeCassetteReader(data json.RawMessage) (interface{}, error) {
+	var value []byte
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(value)), nil
+}
+
+func encodeCassetteString(value interface{}) (json.RawMessage, error) {
+	return json.Marshal(value.(*string))
+}
+
+func decodeCassetteString(data json.RawMessage) (interface{}, error) {
+	var value *string
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// StartRecording records every call of an operation in the cassette file at path
+func (api *apiSt) StartRecording(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	api.mutex.Lock()
+	previous := api.recorder
+	api.recorder = &cassetteRecorder{file: file, encoder: json.NewEncoder(file)}
+	api.mutex.Unlock()
+	if previous != nil {
+		return previous.close()
+	}
+	return nil
+}
+
+// StopRecording stops recording and closes the cassette file
+func (api *apiSt) StopRecording() error {
+	api.mutex.Lock()
+	recorder := api.recorder
+	api.recorder = nil
+	api.mutex.Unlock()
+	if recorder == nil {
+		return nil
+	}
+	return recorder.close()
+}
+
+func (api *apiSt) currentRecorder() *cassetteRecorder {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	return api.recorder
+}
+
+// cassetteRecorder writes the entries of the recorded calls to a cassette file
+type cassetteRecorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// record writes the entry of a call, the reader returned by a server streaming operation
+// is replaced by a reader recording the stream once it has been read or closed
+func (obj *cassetteRecorder) record(codec cassetteCodec, transport string, operation string, request interface{}, start time.Time, resp interface{}, err error) interface{} {
+	entry := &CassetteEntry{Operation: operation, Transport: transport, Start: start}
+	var encodeErr error
+	if entry.Request, encodeErr = codec.encodeRequest(operation, request); encodeErr != nil {
+		logs.Warn("failed to record call", "Operation", operation, "Error", encodeErr.Error())
+		return resp
+	}
+	if err != nil {
+		entry.Error = codec.encodeError(err)
+		entry.Duration = time.Since(start)
+		obj.write(entry)
+		return resp
+	}
+	if reader, ok := resp.(io.ReadCloser); ok {
+		return &recordingReader{reader: reader, recorder: obj, entry: entry, encodeError: codec.encodeError}
+	}
+	if resp != nil {
+		if entry.Response, encodeErr = codec.operations[operation].encodeResponse(resp); encodeErr != nil {
+			logs.Warn("failed to record call", "Operation", operation, "Error", encodeErr.Error())
+			return resp
+		}
+	}
+	entry.Duration = time.Since(start)
+	obj.write(entry)
+	return resp
+}
+
+func (obj *cassetteRecorder) write(entry *CassetteEntry) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	if obj.encoder == nil {
+		// recording has been stopped while the call was in flight
+		return
+	}
+	if err := obj.encoder.Encode(entry); err != nil {
+		logs.Warn("failed to record call", "Operation", entry.Operation, "Error", err.Error())
+	}
+}
+
+func (obj *cassetteRecorder) close() error {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.encoder = nil
+	return obj.file.Close()
+}
+
+// recordingReader records the stream of a server streaming operation as it is read
+type recordingReader struct {
+	reader      io.ReadCloser
+	recorder    *cassetteRecorder
+	entry       *CassetteEntry
+	encodeError func(err error) *CassetteError
+	data        []byte
+	recorded    bool
+}
+
+func (obj *recordingReader) Read(p []byte) (int, error) {
+	n, err := obj.reader.Read(p)
+	obj.data = append(obj.data, p[:n]...)
+	if err != nil {
+		obj.finish(err)
+	}
+	return n, err
+}
+
+// Close records the part of the stream read so far if the stream has not ended yet
+func (obj *recordingReader) Close() error {
+	obj.finish(nil)
+	return obj.reader.Close()
+}
+
+func (obj *recordingReader) finish(err error) {
+	if obj.recorded {
+		return
+	}
+	obj.recorded = true
+	obj.entry.Duration = time.Since(obj.entry.Start)
+	obj.entry.Response, _ = json.Marshal(obj.data)
+	if err != nil && err != io.EOF {
+		obj.entry.Error = obj.encodeError(err)
+	}
+	obj.recorder.write(obj.entry)
+}
+
+// ReplayTransport serves the calls recorded in a cassette file. A call is answered with the next
+// unused entry recorded for the same operation and request, the last matching entry is served
+// again once all of them have been used.
+type ReplayTransport interface {
+	// Path get path of the cassette file
+	Path() string
+	// SetRealtime delays every replayed response by the duration of the recorded call
+	SetRealtime(value bool) ReplayTransport
+	// Realtime get whether replayed responses are delayed by the duration of the recorded call
+	Realtime() bool
+	// Entries get the entries of the cassette file
+	Entries() []CassetteEntry
+}
+
+type replayTransport struct {
+	path     string
+	realtime bool
+	entries  []CassetteEntry
+	mutex    sync.Mutex
+	// served holds the number of times each entry has been served
+	served []int
+}
+
+// NewReplayTransport sets the underlying transport of the Api as the replay of the cassette file at path
+func (api *apiSt) NewReplayTransport(path string) (ReplayTransport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	replay := &replayTransport{path: path}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var entry CassetteEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid cassette file %s: %v", path, err)
+		}
+		replay.entries = append(replay.entries, entry)
+	}
+	replay.served = make([]int, len(replay.entries))
+	api.closeGrpcConnections()
+	api.grpc = nil
+	api.http = nil
+	api.dryRun = nil
+	api.replay = replay
+	return replay, nil
+}
+
+func (api *apiSt) hasReplayTransport() bool {
+	return api.replay != nil
+}
+
+// Path returns the path of the cassette file
+func (obj *replayTransport) Path() string {
+	return obj.path
+}
+
+// SetRealtime delays every replayed response by the duration of the recorded call
+func (obj *replayTransport) SetRealtime(value bool) ReplayTransport {
+	obj.realtime = value
+	return obj
+}
+
+// Realtime returns whether replayed responses are delayed by the duration of the recorded call
+func (obj *replayTransport) Realtime() bool {
+	return obj.realtime
+}
+
+// Entries returns the entries of the cassette file
+func (obj *replayTransport) Entries() []CassetteEntry {
+	return obj.entries
+}
+
+// next returns the entry answering a call, nil if no entry matches it
+func (obj *replayTransport) next(operation string, request json.RawMessage) *CassetteEntry {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	last := -1
+	for i := range obj.entries {
+		entry := &obj.entries[i]
+		if entry.Operation != operation || !bytes.Equal(entry.Request, request) {
+			continue
+		}
+		if obj.served[i] == 0 {
+			obj.served[i]++
+			return entry
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	obj.served[last]++
+	return &obj.entries[last]
+}
+
+func (obj *replayTransport) serve(ctx context.Context, codec cassetteCodec, operation string, request interface{}) (interface{}, error) {
+	encoded, err := codec.encodeRequest(operation, request)
+	if err != nil {
+		return nil, err
+	}
+	entry := obj.next(operation, encoded)
+	if entry == nil {
+		return nil, fmt.Errorf("no call of %s with the same request has been recorded in %s", operation, obj.path)
+	}
+	if obj.realtime {
+		timer := time.NewTimer(entry.Duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if len(entry.Response) == 0 {
+		if entry.Error != nil {
+			return nil, codec.decodeError(entry.Error)
+		}
+		return nil, nil
+	}
+	resp, err := codec.operations[operation].decodeResponse(entry.Response)
+	if err != nil {
+		return nil, err
+	}
+	if reader, ok := resp.(io.ReadCloser); ok && entry.Error != nil {
+		// the recorded stream has failed after the data it has yielded
+		resp = io.NopCloser(io.MultiReader(reader, &failingReader{err: codec.decodeError(entry.Error)}))
+	}
+	return resp, nil
+}
+
+// failingReader fails every read with err
+type failingReader struct {
+	err error
+}
+
+func (obj *failingReader) Read(p []byte) (int, error) {
+	return 0, obj.err
+}
+
+// LoopbackTransport connects an Api to a server implementation in the same process,
+// e.g. to unit test the server or code using the Api without listening on a port
+type LoopbackTransport interface {
+	// SetGrpcServer serves the grpc transport of the Api with server, the services must have
+	// been registered on server which is not stopped when the Api is closed
+	SetGrpcServer(server *grpc.Server) GrpcTransport
+	// SetHttpHandler serves the http transport of the Api with handler
+	// e.g. the router returned by httpapi.AppendRoutes
+	SetHttpHandler(handler http.Handler) HttpTransport
+}
+
+type loopbackTransport struct {
+	api *apiSt
+}
+
+// loopbackServer serves the in-memory connections of a loopback transport until it is closed
+type loopbackServer struct {
+	listener *bufconn.Listener
+	// close stops serving the listener
+	close func()
+}
+
+// loopbackBufferSize is the size of the in-memory buffers of the loopback connections
+const loopbackBufferSize = 1024 * 1024
+
+// NewLoopbackTransport connects the Api in process to a grpc server or an http handler
+func (api *apiSt) NewLoopbackTransport() LoopbackTransport {
+	return &loopbackTransport{api: api}
+}
+
+// SetGrpcServer sets the underlying transport of the Api as grpc served in memory by server
+func (obj *loopbackTransport) SetGrpcServer(server *grpc.Server) GrpcTransport {
+	listener := bufconn.Listen(loopbackBufferSize)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	obj.api.setLoopback(&loopbackServer{
+		listener: listener,
+		// only the listener is closed as the server may serve other listeners
+		close: func() { _ = listener.Close() },
+	})
+	transport := obj.api.NewGrpcTransport()
+	obj.api.grpc.SetLocation("passthrough:///loopback")
+	obj.api.grpc.loopback = listener.DialContext
+	return transport
+}
+
+// SetHttpHandler sets the underlying transport of the Api as http served in memory by handler
+func (obj *loopbackTransport) SetHttpHandler(handler http.Handler) HttpTransport {
+	listener := bufconn.Listen(loopbackBufferSize)
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	obj.api.setLoopback(&loopbackServer{
+		listener: listener,
+		close:    func() { _ = server.Close() },
+	})
+	transport := obj.api.NewHttpTransport()
+	obj.api.http.SetLocation("http://loopback")
+	obj.api.http.loopback = listener.DialContext
+	return transport
+}
+
+// setLoopback replaces the loopback server of the Api
+func (api *apiSt) setLoopback(server *loopbackServer) {
+	api.mutex.Lock()
+	previous := api.loopback
+	api.loopback = server
+	api.mutex.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// closeLoopback stops serving the loopback transport of the Api if any
+func (api *apiSt) closeLoopback() {
+	api.setLoopback(nil)
+}
+
+const (
+	WireFormatGrpc = "grpc"
+	WireFormatHttp = "http"
+)
+
+// DryRunRequest is a request collected by a dry-run transport instead of being sent
+type DryRunRequest struct {
+	// Operation is the name of the Api method e.g. SetConfig
+	Operation string
+	// Request is the request object of the operation,
+	// []byte for binary requests and nil for operations without a request body
+	Request interface{}
+	// WireFormat is the format Body is encoded in, WireFormatGrpc or WireFormatHttp
+	WireFormat string
+	// Method is the full name of the grpc method e.g. /sanity.Openapi/SetConfig
+	// or the http method e.g. POST
+	Method string
+	// Path is the path of the http request relative to the location, empty for grpc
+	Path string
+	// Body is the protobuf encoding of the grpc request message or the body of the http request,
+	// before any compression
+	Body []byte
+}
+
+type DryRunTransport interface {
+	// SetWireFormat sets the format the requests are encoded in,
+	// WireFormatGrpc which is the default or WireFormatHttp
+	SetWireFormat(value string) DryRunTransport
+	// WireFormat returns the format the requests are encoded in
+	WireFormat() string
+	// Requests returns the requests collected so far in the order they have been made
+	Requests() []DryRunRequest
+	// Reset forgets the requests collected so far
+	Reset()
+}
+
+type dryRunTransport struct {
+	wireFormat string
+	mutex      sync.Mutex
+	requests   []DryRunRequest
+}
+
+// wireOperation encodes the requests of an operation as the grpc and the http transports send them
+type wireOperation struct {
+	grpcMethod string
+	httpMethod string
+	httpPath   string
+	// encodeGrpc and encodeHttp are nil for operations without a request body
+	encodeGrpc func(request interface{}) ([]byte, error)
+	encodeHttp func(request interface{}) ([]byte, error)
+	// emptyResponse returns the response of the operation collected by a dry-run transport
+	emptyResponse func() interface{}
+}
+
+// NewDryRunTransport sets the underlying transport of the Api as a dry run
+func (api *apiSt) NewDryRunTransport() DryRunTransport {
+	api.closeGrpcConnections()
+	api.grpc = nil
+	api.http = nil
+	api.replay = nil
+	api.dryRun = &dryRunTransport{wireFormat: WireFormatGrpc}
+	return api.dryRun
+}
+
+// SetWireFormat sets the format the requests are encoded in
+func (obj *dryRunTransport) SetWireFormat(value string) DryRunTransport {
+	if value != WireFormatGrpc && value != WireFormatHttp {
+		fmt.Printf("The wire format %s is not supported, so will not be considered. supported values are %s and %s\n", value, WireFormatGrpc, WireFormatHttp)
+		return obj
+	}
+	obj.wireFormat = value
+	return obj
+}
+
+// WireFormat returns the format the requests are encoded in
+func (obj *dryRunTransport) WireFormat() string {
+	return obj.wireFormat
+}
+
+// Requests returns the requests collected so far in the order they have been made
+func (obj *dryRunTransport) Requests() []DryRunRequest {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return append([]DryRunRequest{}, obj.requests...)
+}
+
+// Reset forgets the requests collected so far
+func (obj *dryRunTransport) Reset() {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.requests = nil
+}
+
+// collect encodes and records a request, it returns the empty response of its operation
+func (obj *dryRunTransport) collect(wire map[string]wireOperation, operation string, request interface{}) (interface{}, error) {
+	wireOp, ok := wire[operation]
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be dry run", operation)
+	}
+	collected := DryRunRequest{Operation: operation, Request: request, WireFormat: obj.wireFormat}
+	encode := wireOp.encodeGrpc
+	collected.Method = wireOp.grpcMethod
+	if obj.wireFormat == WireFormatHttp {
+		encode = wireOp.encodeHttp
+		collected.Method = wireOp.httpMethod
+		collected.Path = wireOp.httpPath
+	}
+	if encode != nil {
+		body, err := encode(request)
+		if err != nil {
+			return nil, err
+		}
+		collected.Body = body
+	}
+	obj.mutex.Lock()
+	obj.requests = append(obj.requests, collected)
+	obj.mutex.Unlock()
+	return wireOp.emptyResponse(), nil
+}
+
+// encodeWireBytes encodes the request of a binary operation sent as is
+func encodeWireBytes(request interface{}) ([]byte, error) {
+	return request.([]byte), nil
+}
+
+// emptyReader is the empty response of the reader of a streamed operation
+func emptyReader() interface{} {
+	return io.NopCloser(bytes.NewReader(nil))
+}
+
+// FakeCall is a call received by a FakeApi
+type FakeCall struct {
+	// Operation is the name of the Api method e.g. SetConfig
+	Operation string
+	// Request is the request object of the operation,
+	// []byte for binary requests and nil for operations without a request body
+	Request interface{}
+}
+
+// FakeHandler computes the response of a call received by a FakeApi,
+// the response must have the type returned by the Api method of the operation
+type FakeHandler func(ctx context.Context, request interface{}) (interface{}, error)
+
+// fakeCalls holds the programmed handlers and the received calls of a FakeApi
+type fakeCalls struct {
+	mutex    sync.Mutex
+	handlers map[string]FakeHandler
+	calls    []FakeCall
+}
+
+func newFakeCalls() *fakeCalls {
+	return &fakeCalls{handlers: map[string]FakeHandler{}}
+}
+
+func (obj *fakeCalls) setHandler(operation string, handler FakeHandler) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.handlers[operation] = handler
+}
+
+// call records a call and answers it with the handler programmed for its operation
+func (obj *fakeCalls) call(ctx context.Context, operation string, request interface{}) (interface{}, error) {
+	obj.mutex.Lock()
+	obj.calls = append(obj.calls, FakeCall{Operation: operation, Request: request})
+	handler, ok := obj.handlers[operation]
+	obj.mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no response has been programmed for %s", operation)
+	}
+	return handler(ctx, request)
+}
+
+// Calls returns the calls received so far in the order they have been made
+func (obj *fakeCalls) Calls() []FakeCall {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	return append([]FakeCall{}, obj.calls...)
+}
+
+// CallsTo returns the calls of operation received so far in the order they have been made
+func (obj *fakeCalls) CallsTo(operation string) []FakeCall {
+	var calls []FakeCall
+	for _, call := range obj.Calls() {
+		if call.Operation == operation {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// VerifyCallOrder returns an error unless operations have been called in the given order,
+// other calls may have been made before, after or in between them
+func (obj *fakeCalls) VerifyCallOrder(operations ...string) error {
+	calls := obj.Calls()
+	received := make([]string, len(calls))
+	next := 0
+	for i, call := range calls {
+		received[i] = call.Operation
+		if next < len(operations) && call.Operation == operations[next] {
+			next++
+		}
+	}
+	if next < len(operations) {
+		return fmt.Errorf("expected calls of %s in this order, received calls of [%s]",
+			strings.Join(operations, ", "), strings.Join(received, ", "))
+	}
+	return nil
+}
+
+// ResetCalls forgets the calls received so far, the programmed responses are kept
+func (obj *fakeCalls) ResetCalls() {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.calls = nil
+}
+
+// fakeReader returns the reader of a server streaming operation of a FakeApi
+// from the data or the reader returned by its handler
+func fakeReader(operation string, resp interface{}) (io.ReadCloser, error) {
+	switch value := resp.(type) {
+	case io.ReadCloser:
+		return value, nil
+	case []byte:
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+	return nil, fmt.Errorf("%s received response of type %T instead of []byte or io.ReadCloser", operation, resp)
+}
+
+// TargetResult is the outcome of a MultiApi request for one of its locations
+type TargetResult struct {
+	// Location is the location the request has been sent to
+	Location string
+	// Response has the type returned by the Api method of the operation, it is nil when Err is set
+	Response interface{}
+	// Err is the error returned for the location, ErrFanoutAborted when the request has not been sent
+	Err error
+	// Duration is the time taken by the request
+	Duration time.Duration
+}
+
+// ErrFanoutAborted is the error of the locations a fail-fast MultiApi request has not been sent to
+// because it had already failed on another location
+var ErrFanoutAborted = errors.New("request not sent, it has failed on another location")
+
+// FanoutResults holds the outcome of a MultiApi request for every location keyed by location
+type FanoutResults map[string]*TargetResult
+
+// Responses returns the responses of the locations the request has succeeded on keyed by location
+func (obj FanoutResults) Responses() map[string]interface{} {
+	responses := map[string]interface{}{}
+	for location, result := range obj {
+		if result.Err == nil {
+			responses[location] = result.Response
+		}
+	}
+	return responses
+}
+
+// Errors returns the errors of the locations the request has failed on keyed by location
+func (obj FanoutResults) Errors() map[string]error {
+	errs := map[string]error{}
+	for location, result := range obj {
+		if result.Err != nil {
+			errs[location] = result.Err
+		}
+	}
+	return errs
+}
+
+// FanoutError is returned by a MultiApi request which has failed on some of its locations
+type FanoutError struct {
+	// Operation is the name of the Api method e.g. SetConfig
+	Operation string
+	// Errors holds the errors of the locations the request has failed on keyed by location
+	Errors map[string]error
+	// Locations is the number of locations the request was meant for
+	Locations int
+}
+
+func (obj *FanoutError) Error() string {
+	locations := make([]string, 0, len(obj.Errors))
+	for location := range obj.Errors {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	errs := make([]string, len(locations))
+	for i, location := range locations {
+		errs[i] = fmt.Sprintf("%s: %v", location, obj.Errors[location])
+	}
+	return fmt.Sprintf("%s failed on %d of %d locations: %s",
+		obj.Operation, len(obj.Errors), obj.Locations, strings.Join(errs, "; "))
+}
+
+// fanout sends the requests of a MultiApi to each of its locations concurrently
+type fanout struct {
+	locations      []string
+	maxConcurrency int
+	failFast       bool
+	tracer         Telemetry
+}
+
+// newFanout returns a fanout to the given locations, a repeated location is only sent requests once
+func newFanout(locations []string) *fanout {
+	obj := &fanout{maxConcurrency: 16, tracer: &telemetry{transport: "HTTP", serviceName: "go-snappi"}}
+	for _, location := range locations {
+		if !contains(obj.locations, location) {
+			obj.locations = append(obj.locations, location)
+		}
+	}
+	return obj
+}
+
+// run calls send for the index of every location within a span summarizing the outcome, at most maxConcurrency
+// calls are in flight at once. With fail-fast the first failure cancels the calls in flight and aborts the others.
+func (obj *fanout) run(ctx context.Context, operation string, send func(ctx context.Context, index int) (interface{}, error)) (FanoutResults, error) {
+	newCtx, span := obj.tracer.NewSpan(ctx, "Fanout"+operation, trace.WithSpanKind(trace.SpanKindInternal))
+	defer obj.tracer.CloseSpan(span)
+	if newCtx != nil {
+		ctx = newCtx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(FanoutResults, len(obj.locations))
+	var mutex sync.Mutex
+	aborted := false
+	workers := obj.maxConcurrency
+	if workers <= 0 || workers > len(obj.locations) {
+		workers = len(obj.locations)
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := &TargetResult{Location: obj.locations[index]}
+				mutex.Lock()
+				skip := aborted
+				mutex.Unlock()
+				if skip {
+					result.Err = ErrFanoutAborted
+				} else {
+					start := time.Now()
+					result.Response, result.Err = send(ctx, index)
+					result.Duration = time.Since(start)
+				}
+				mutex.Lock()
+				results[result.Location] = result
+				if result.Err != nil && obj.failFast && !aborted {
+					aborted = true
+					cancel()
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for index := range obj.locations {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	errs := results.Errors()
+	obj.tracer.SetSpanAttributes(span, []attribute.KeyValue{
+		attribute.Int("fanout.locations", len(obj.locations)),
+		attribute.Int("fanout.succeeded", len(obj.locations)-len(errs)),
+		attribute.Int("fanout.failed", len(errs)),
+		attribute.Bool("fanout.fail_fast", obj.failFast),
+	})
+	if len(errs) == 0 {
+		return results, nil
+	}
+	err := &FanoutError{Operation: operation, Errors: errs, Locations: len(obj.locations)}
+	for location, locationErr := range errs {
+		obj.tracer.SetSpanEvent(span, fmt.Sprintf("%s failed: %v", location, locationErr))
+	}
+	obj.tracer.SetSpanStatus(span, codes.Error, err.Error())
+	return results, err
 }
 
 // HttpRequestDoer will return True for HTTP transport
@@ -247,7 +3497,9 @@ type httpRequestDoer interface {
 
 type httpClient struct {
 	client httpRequestDoer
-	ctx    context.Context
+	// transport holds the connection pool drained on Close
+	transport *http.Transport
+	ctx       context.Context
 }
 
 // All methods that perform validation will add errors here
@@ -0,0 +1,142 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
+)
+
+// compressionRecorder records the compression of the messages sent by a grpc client,
+// or of the messages received by a grpc server when received is set
+type compressionRecorder struct {
+	mutex        sync.Mutex
+	compressions []string
+	received     bool
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	compression := ""
+	switch header := s.(type) {
+	case *stats.OutHeader:
+		if r.received {
+			return
+		}
+		compression = header.Compression
+	case *stats.InHeader:
+		if !r.received {
+			return
+		}
+		compression = header.Compression
+	default:
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.compressions = append(r.compressions, compression)
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *compressionRecorder) recorded() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.compressions...)
+}
+
+func decompress(t *testing.T, encoding string, data []byte) []byte {
+	var reader io.Reader
+	var err error
+	if encoding == openapiart.CompressionZstd {
+		decoder, e := zstd.NewReader(bytes.NewReader(data))
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer decoder.Close()
+		reader = decoder
+	} else if reader, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	return decompressed
+}
+
+func TestGrpcCompression(t *testing.T) {
+	for _, compression := range []string{openapiart.CompressionGzip, openapiart.CompressionZstd} {
+		recorder := &compressionRecorder{}
+		conn, err := grpc.Dial(grpcServer.Location, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithStatsHandler(recorder))
+		if err != nil {
+			t.Fatal(err)
+		}
+		api := openapiart.NewApi()
+		transport := api.NewGrpcTransport().SetClientConnection(conn).SetCompression(compression)
+		assert.Equal(t, compression, transport.Compression())
+
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		metrics, err := api.GetMetrics(metReq)
+		assert.Nil(t, err)
+		assert.NotNil(t, metrics)
+
+		transport.EnableGrpcStreaming().SetStreamChunkSize(1)
+		data := bytes.Repeat([]byte("compressible "), megabyte/4)
+		_, err = api.UploadConfig(data)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+		assert.Equal(t, []string{compression, compression}, recorder.recorded())
+		conn.Close()
+	}
+}
+
+func TestGrpcCompressionUnsupported(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetCompression("br")
+	assert.Equal(t, openapiart.CompressionNone, transport.Compression())
+}
+
+func TestHttpCompression(t *testing.T) {
+	server, recorder := startUploadRecorder(t)
+	for _, compression := range []string{openapiart.CompressionGzip, openapiart.CompressionZstd} {
+		api := openapiart.NewApi()
+		transport := api.NewHttpTransport().SetLocation(server.URL).SetCompression(compression)
+		assert.Equal(t, compression, transport.Compression())
+
+		data := bytes.Repeat([]byte("0123456789"), 1000)
+		warnings, err := api.UploadConfig(data)
+		assert.Nil(t, err)
+		assert.NotNil(t, warnings)
+		assert.Equal(t, compression, recorder.contentEncoding)
+		assert.Less(t, len(recorder.body), len(data))
+		assert.Equal(t, data, decompress(t, compression, recorder.body))
+		assert.Equal(t, compression, recorder.responseEncoding)
+
+		// requests without a body are not compressed but accept a compressed response
+		reader, err := api.GetCaptureStream(context.Background())
+		assert.Nil(t, err)
+		capture, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Nil(t, reader.Close())
+		assert.Equal(t, []byte("Successful set config operation"), capture)
+		assert.Empty(t, recorder.contentEncoding)
+		assert.Equal(t, compression, recorder.responseEncoding)
+		assert.Nil(t, api.Close())
+	}
+}
@@ -0,0 +1,89 @@
+package openapiart_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// these tests are meant to be run with go test -race
+
+const concurrentCallers = 16
+
+// callConcurrently runs call from concurrentCallers goroutines and waits for them to return
+func callConcurrently(call func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentCallers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			call(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	// fresh apis so that the connections are established concurrently
+	for _, a := range newMockApis(openapiart.NewBearerAuthAuthenticator("token-1")) {
+		api := a.api
+		var intercepted int64
+		api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+			atomic.AddInt64(&intercepted, 1)
+			return next(ctx, invocation)
+		})
+		errs := make([]error, concurrentCallers*2)
+		callConcurrently(func(i int) {
+			_, errs[2*i] = api.GetWarnings()
+			metReq := openapiart.NewMetricsRequest()
+			metReq.SetPort("p1")
+			_, errs[2*i+1] = api.GetMetrics(metReq)
+		})
+		for _, err := range errs {
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, int64(concurrentCallers*2), atomic.LoadInt64(&intercepted))
+		assert.Nil(t, api.Close())
+	}
+}
+
+func TestConcurrentVersionCheck(t *testing.T) {
+	for _, a := range newMockApis(nil) {
+		api := a.api
+		api.SetVersionCompatibilityCheck(true)
+		errs := make([]error, concurrentCallers)
+		callConcurrently(func(i int) {
+			_, errs[i] = api.GetWarnings()
+		})
+		for _, err := range errs {
+			assert.Nil(t, err)
+		}
+		remote, err := api.GetRemoteVersion()
+		assert.Nil(t, err)
+		assert.Equal(t, api.GetLocalVersion().ApiSpecVersion(), remote.ApiSpecVersion())
+	}
+}
+
+func TestConcurrentWarnings(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	_, err := api.SetConfig(config)
+	assert.Nil(t, err)
+
+	errs := make([]error, concurrentCallers)
+	callConcurrently(func(i int) {
+		update := openapiart.NewUpdateConfig()
+		update.G().Add().SetName("G1").SetGA("ga string").SetGB(232)
+		// the deprecated operation records a warning on every call
+		_, errs[i] = api.UpdateConfiguration(update)
+	})
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,101 @@
+package openapiart_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDryRunGrpc(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewDryRunTransport()
+	assert.Equal(t, openapiart.WireFormatGrpc, transport.WireFormat())
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.Empty(t, resp)
+	// x-status warnings are raised as they are for a real transport
+	assert.Contains(t, config.Warnings(), "A property in schema PrefixConfig is under review, Information TBD")
+
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	metrics, err := api.GetMetrics(metReq)
+	assert.Nil(t, err)
+	assert.Empty(t, metrics.Ports().Items())
+
+	requests := transport.Requests()
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "SetConfig", requests[0].Operation)
+	assert.Equal(t, config, requests[0].Request)
+	assert.Equal(t, "/sanity.Openapi/SetConfig", requests[0].Method)
+	assert.Empty(t, requests[0].Path)
+	sent := &sanity.SetConfigRequest{}
+	assert.Nil(t, proto.Unmarshal(requests[0].Body, sent))
+	expected, err := config.Marshal().ToProto()
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(expected, sent.PrefixConfig))
+	assert.Equal(t, "/sanity.Openapi/GetMetrics", requests[1].Method)
+
+	transport.Reset()
+	assert.Empty(t, transport.Requests())
+}
+
+func TestDryRunHttp(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewDryRunTransport().SetWireFormat(openapiart.WireFormatHttp)
+	assert.Equal(t, openapiart.WireFormatHttp, transport.WireFormat())
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	_, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	_, err = api.UploadConfig([]byte("uploaded config"))
+	assert.Nil(t, err)
+	warnings, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Empty(t, warnings.Warnings())
+
+	requests := transport.Requests()
+	assert.Len(t, requests, 3)
+	assert.Equal(t, "POST", requests[0].Method)
+	assert.Equal(t, "api/config", requests[0].Path)
+	body, err := config.Marshal().ToJson()
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(requests[0].Body))
+	assert.Equal(t, "api/file/upload", requests[1].Path)
+	assert.Equal(t, []byte("uploaded config"), requests[1].Body)
+	assert.Equal(t, "GET", requests[2].Method)
+	assert.Empty(t, requests[2].Body)
+}
+
+func TestDryRunValidation(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewDryRunTransport()
+	_, err := api.SetConfig(openapiart.NewPrefixConfig())
+	assert.NotNil(t, err)
+	assert.Empty(t, transport.Requests())
+}
+
+func TestDryRunStream(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewDryRunTransport()
+	reader, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+	capture, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Empty(t, capture)
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, "/sanity.Openapi/streamGetCapture", transport.Requests()[0].Method)
+
+	// a transport set afterwards sends the requests again
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	assert.Nil(t, getMetrics(api))
+	assert.Len(t, transport.Requests(), 1)
+	assert.Nil(t, api.Close())
+}
@@ -0,0 +1,285 @@
+package openapiart_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unreachableLocation refuses every connection
+const unreachableLocation = "127.0.0.1:1"
+
+// locationServer serves the mock http router and counts the requests it receives
+type locationServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests int
+}
+
+func (s *locationServer) received() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// startLocationServer starts a location server, handler answers the requests it handles
+// instead of the mock router by returning true
+func startLocationServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request) bool) *locationServer {
+	router := NewMockHttpRouter()
+	server := &locationServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.requests++
+		server.mutex.Unlock()
+		if handler != nil && handler(w, r) {
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getMetrics(api openapiart.Api) error {
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	_, err := api.GetMetrics(metReq)
+	return err
+}
+
+func TestHttpFailover(t *testing.T) {
+	primary := startLocationServer(t, nil)
+	secondary := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocations([]string{"http://" + unreachableLocation, primary.URL, secondary.URL})
+	assert.Equal(t, "http://"+unreachableLocation, transport.Location())
+	assert.Equal(t, []string{"http://" + unreachableLocation, primary.URL, secondary.URL}, transport.Locations())
+
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, primary.URL, transport.ActiveLocation())
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, 2, primary.received())
+
+	primary.Close()
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, secondary.URL, transport.ActiveLocation())
+	assert.Equal(t, 1, secondary.received())
+}
+
+func TestHttpFailoverServiceUnavailable(t *testing.T) {
+	standby := startLocationServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	active := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocations([]string{standby.URL, active.URL})
+
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, active.URL, transport.ActiveLocation())
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, 1, standby.received())
+	assert.Equal(t, 2, active.received())
+}
+
+func TestFailoverNotIdempotent(t *testing.T) {
+	standby := startLocationServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	active := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocations([]string{standby.URL, active.URL})
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+
+	// the standby may have processed the request which is not sent again
+	_, err := api.SetConfig(config)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, standby.received())
+	assert.Equal(t, 0, active.received())
+
+	// a request which could not be sent goes to the next location
+	api = openapiart.NewApi()
+	api.NewHttpTransport().SetLocations([]string{"http://" + unreachableLocation, active.URL})
+	_, err = api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, active.received())
+}
+
+func TestHttpFailoverStream(t *testing.T) {
+	standby := startLocationServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	active := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocations([]string{standby.URL, active.URL})
+
+	// the streaming call of an idempotent operation fails over like the operation
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	reader, err := api.GetMetricsStream(context.Background(), metReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+	assert.Equal(t, 1, standby.received())
+	assert.Equal(t, 1, active.received())
+}
+
+func TestHttpFailoverCooldown(t *testing.T) {
+	first := startLocationServer(t, nil)
+	second := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocations([]string{first.URL, second.URL}).EnableRoundRobin().SetFailoverCooldown(time.Hour)
+	assert.Equal(t, time.Hour, transport.FailoverCooldown())
+
+	first.Close()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, getMetrics(api))
+	}
+	// the closed location is skipped once it has failed
+	assert.Equal(t, 4, second.received())
+}
+
+func TestHttpRoundRobin(t *testing.T) {
+	first := startLocationServer(t, nil)
+	second := startLocationServer(t, nil)
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocations([]string{first.URL, second.URL}).EnableRoundRobin()
+
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, getMetrics(api))
+	}
+	assert.Equal(t, 2, first.received())
+	assert.Equal(t, 2, second.received())
+
+	transport.DisableRoundRobin()
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, getMetrics(api))
+	}
+	// the requests go to the location which has answered the last one
+	assert.Equal(t, 2, first.received())
+	assert.Equal(t, 4, second.received())
+}
+
+func TestFailoverVersionCheck(t *testing.T) {
+	primary := startLocationServer(t, nil)
+	// the secondary runs an incompatible version
+	secondary := startLocationServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/api/capabilities/version" {
+			return false
+		}
+		version, _ := openapiart.NewVersion().SetApiSpecVersion("2.0.0").SetSdkVersion("2.0.0").Marshal().ToJson()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(version))
+		return true
+	})
+	api := openapiart.NewApi()
+	api.SetVersionCompatibilityCheck(true)
+	transport := api.NewHttpTransport().SetLocations([]string{primary.URL, secondary.URL})
+
+	assert.Nil(t, getMetrics(api))
+	assert.Nil(t, getMetrics(api))
+	// the version is fetched once along with the first request
+	assert.Equal(t, 3, primary.received())
+
+	primary.Close()
+	err := getMetrics(api)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not compatible")
+	assert.Equal(t, secondary.URL, transport.ActiveLocation())
+	remote, err := api.GetRemoteVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, "2.0.0", remote.ApiSpecVersion())
+}
+
+func TestGrpcFailover(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mutex sync.Mutex
+	received := 0
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mutex.Lock()
+		received++
+		mutex.Unlock()
+		return handler(ctx, req)
+	}))
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocations([]string{unreachableLocation, listener.Addr().String()})
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	_, err = api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.Equal(t, listener.Addr().String(), transport.ActiveLocation())
+	assert.NotNil(t, transport.ClientConnection())
+
+	assert.Nil(t, getMetrics(api))
+	mutex.Lock()
+	assert.Equal(t, 2, received)
+	mutex.Unlock()
+	assert.Nil(t, api.Close())
+}
+
+func TestGrpcFailoverNotIdempotent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the standby refuses every call as unavailable
+	standby := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "standby")
+	}))
+	sanity.RegisterOpenapiServer(standby, &grpcServer)
+	go func() {
+		_ = standby.Serve(listener)
+	}()
+	t.Cleanup(standby.Stop)
+
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocations([]string{listener.Addr().String(), grpcServer.Location})
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	// the standby may have processed the request which is not sent again
+	_, err = api.SetConfig(config)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "standby")
+
+	// an idempotent request goes to the next location
+	assert.Nil(t, getMetrics(api))
+	assert.Equal(t, grpcServer.Location, transport.ActiveLocation())
+	assert.Nil(t, api.Close())
+}
+
+func TestFailoverAllLocationsUnreachable(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocations([]string{unreachableLocation, "127.0.0.1:2"})
+	err := getMetrics(api)
+	assert.NotNil(t, err)
+	errSt, ok := openapiart.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(14), errSt.Code())
+}
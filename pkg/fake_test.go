@@ -0,0 +1,165 @@
+package openapiart_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeApiReturns(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	warnings := openapiart.NewWarningDetails()
+	warnings.SetWarnings([]string{"w1"})
+	api.SetConfigReturns([]byte("configured"), nil).GetWarningsReturns(warnings, nil)
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("configured"), resp)
+	received, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"w1"}, received.Warnings())
+
+	apiErr := openapiart.NewError()
+	apiErr.SetCode(500)
+	apiErr.SetErrors([]string{"server failure"})
+	api.SetConfigReturns(nil, apiErr)
+	_, err = api.SetConfig(config)
+	assert.NotNil(t, err)
+	errSt, ok := openapiart.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(500), errSt.Code())
+}
+
+func TestFakeApiNotProgrammed(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	_, err := api.GetWarnings()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no response has been programmed for GetWarnings")
+	// the call is recorded even though it failed
+	assert.Len(t, api.CallsTo("GetWarnings"), 1)
+}
+
+func TestFakeApiHandler(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.SetHandler("GetMetrics", func(ctx context.Context, request interface{}) (interface{}, error) {
+		port := request.(openapiart.MetricsRequest).Port()
+		if port != "p1" {
+			return nil, fmt.Errorf("unknown port %s", port)
+		}
+		metrics := openapiart.NewMetrics()
+		metrics.Ports().Add().SetName(port).SetTxFrames(10)
+		return metrics, nil
+	})
+
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	metrics, err := api.GetMetrics(metReq)
+	assert.Nil(t, err)
+	assert.Equal(t, "p1", metrics.Ports().Items()[0].Name())
+
+	metReq.SetPort("p2")
+	_, err = api.GetMetrics(metReq)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown port p2")
+
+	api.SetHandler("GetMetrics", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return openapiart.NewWarningDetails(), nil
+	})
+	_, err = api.GetMetrics(metReq)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "instead of Metrics")
+}
+
+func TestFakeApiValidation(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.SetConfigReturns([]byte("configured"), nil)
+	// required fields are missing
+	_, err := api.SetConfig(openapiart.NewPrefixConfig())
+	assert.NotNil(t, err)
+	assert.Empty(t, api.Calls())
+}
+
+func TestFakeApiCalls(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.SetConfigReturns([]byte("configured"), nil).
+		GetWarningsReturns(openapiart.NewWarningDetails(), nil).
+		UploadConfigReturns(openapiart.NewWarningDetails(), nil)
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	_, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	_, err = api.GetWarnings()
+	assert.Nil(t, err)
+	_, err = api.UploadConfig([]byte("uploaded config"))
+	assert.Nil(t, err)
+	_, err = api.GetWarnings()
+	assert.Nil(t, err)
+
+	calls := api.Calls()
+	assert.Len(t, calls, 4)
+	assert.Equal(t, "SetConfig", calls[0].Operation)
+	assert.Equal(t, config, calls[0].Request)
+	assert.Nil(t, calls[1].Request)
+	assert.Equal(t, []byte("uploaded config"), calls[2].Request)
+	assert.Len(t, api.CallsTo("GetWarnings"), 2)
+
+	assert.Nil(t, api.VerifyCallOrder("SetConfig", "UploadConfig"))
+	assert.Nil(t, api.VerifyCallOrder("SetConfig", "GetWarnings", "GetWarnings"))
+	err = api.VerifyCallOrder("UploadConfig", "SetConfig")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "[SetConfig, GetWarnings, UploadConfig, GetWarnings]")
+
+	api.ResetCalls()
+	assert.Empty(t, api.Calls())
+	_, err = api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Len(t, api.Calls(), 1)
+}
+
+func TestFakeApiStream(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.GetCaptureStreamReturns([]byte("capture"), nil)
+	for i := 0; i < 2; i++ {
+		reader, err := api.GetCaptureStream(context.Background())
+		assert.Nil(t, err)
+		capture, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, "capture", string(capture))
+		assert.Nil(t, reader.Close())
+	}
+
+	api.GetCaptureStreamReturns(nil, fmt.Errorf("capture failure"))
+	_, err := api.GetCaptureStream(context.Background())
+	assert.NotNil(t, err)
+	assert.Len(t, api.CallsTo("GetCaptureStream"), 3)
+}
+
+func TestFakeApiInterceptor(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.GetWarningsReturns(openapiart.NewWarningDetails(), nil)
+	var transports []string
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		transports = append(transports, invocation.Transport)
+		return next(ctx, invocation)
+	})
+	_, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fake"}, transports)
+}
+
+func TestFakeApiVersion(t *testing.T) {
+	api := openapiart.NewFakeApi()
+	api.GetVersionReturns(openapiart.NewVersion().SetApiSpecVersion("2.0.0").SetSdkVersion("2.0.0"), nil)
+	assert.NotNil(t, api.CheckVersionCompatibility())
+
+	api.GetVersionReturns(api.GetLocalVersion(), nil)
+	assert.Nil(t, api.CheckVersionCompatibility())
+	remote, err := api.GetRemoteVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, api.GetLocalVersion().ApiSpecVersion(), remote.ApiSpecVersion())
+}
@@ -0,0 +1,137 @@
+package openapiart_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// connectHttp sets an http transport to the location of the Api of a MultiApi
+func connectHttp(api openapiart.Api, location string) {
+	api.NewHttpTransport().SetLocation(location)
+}
+
+func TestMultiApiBestEffort(t *testing.T) {
+	first := startLocationServer(t, nil)
+	second := startLocationServer(t, nil)
+	unreachable := "http://" + unreachableLocation
+	api := openapiart.NewMultiApi([]string{first.URL, unreachable, second.URL}, connectHttp)
+	assert.Equal(t, []string{first.URL, unreachable, second.URL}, api.Locations())
+	assert.False(t, api.FailFast())
+
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	results, err := api.GetMetrics(metReq)
+	assert.NotNil(t, err)
+	assert.Len(t, results, 3)
+	assert.Len(t, results.Responses(), 2)
+	_, ok := results.Responses()[first.URL].(openapiart.Metrics)
+	assert.True(t, ok)
+	assert.Nil(t, results[second.URL].Err)
+	assert.NotNil(t, results[unreachable].Err)
+
+	var fanoutErr *openapiart.FanoutError
+	assert.True(t, errors.As(err, &fanoutErr))
+	assert.Equal(t, "GetMetrics", fanoutErr.Operation)
+	assert.Equal(t, 3, fanoutErr.Locations)
+	assert.Len(t, fanoutErr.Errors, 1)
+	assert.Contains(t, err.Error(), "GetMetrics failed on 1 of 3 locations: "+unreachable)
+	assert.Nil(t, api.Close())
+}
+
+func TestMultiApiFailFast(t *testing.T) {
+	server := startLocationServer(t, nil)
+	unreachable := "http://" + unreachableLocation
+	api := openapiart.NewMultiApi([]string{unreachable, server.URL}, connectHttp).SetMaxConcurrency(1).EnableFailFast()
+	assert.True(t, api.FailFast())
+
+	results, err := api.GetWarnings()
+	assert.NotNil(t, err)
+	assert.NotNil(t, results[unreachable].Err)
+	assert.True(t, errors.Is(results[server.URL].Err, openapiart.ErrFanoutAborted))
+	assert.Equal(t, 0, server.received())
+
+	api.DisableFailFast()
+	results, err = api.GetWarnings()
+	assert.NotNil(t, err)
+	assert.Nil(t, results[server.URL].Err)
+	assert.Equal(t, 1, server.received())
+}
+
+func TestMultiApiMaxConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	handler := func(w http.ResponseWriter, r *http.Request) bool {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		return false
+	}
+	var locations []string
+	for i := 0; i < 6; i++ {
+		locations = append(locations, startLocationServer(t, handler).URL)
+	}
+	api := openapiart.NewMultiApi(locations, connectHttp).SetMaxConcurrency(2)
+	assert.Equal(t, 2, api.MaxConcurrency())
+
+	results, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Len(t, results.Responses(), 6)
+	for _, result := range results {
+		assert.True(t, result.Duration >= 50*time.Millisecond)
+	}
+	assert.Equal(t, 2, maxInFlight)
+}
+
+func TestMultiApiRequest(t *testing.T) {
+	first := startLocationServer(t, nil)
+	second := startLocationServer(t, nil)
+	api := openapiart.NewMultiApi([]string{first.URL, second.URL}, connectHttp)
+
+	_, err := api.SetConfig(openapiart.NewPrefixConfig())
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, first.received()+second.received())
+
+	config := NewFullyPopulatedPrefixConfig(api.Api(first.URL))
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	results, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.Len(t, results.Responses(), 2)
+	assert.Equal(t, 1, first.received())
+	assert.Equal(t, 1, second.received())
+}
+
+func TestMultiApiDo(t *testing.T) {
+	// a repeated location is sent the requests once
+	api := openapiart.NewMultiApi([]string{grpcServer.Location, grpcServer.Location}, nil)
+	assert.Equal(t, []string{grpcServer.Location}, api.Locations())
+	assert.NotNil(t, api.Api(grpcServer.Location))
+	assert.Nil(t, api.Api("unknown"))
+
+	results, err := api.Do(context.Background(), "PortCount", func(ctx context.Context, api openapiart.Api) (interface{}, error) {
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		metrics, err := api.GetMetricsCtx(ctx, metReq)
+		if err != nil {
+			return nil, err
+		}
+		return len(metrics.Ports().Items()), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{grpcServer.Location: 2}, results.Responses())
+	assert.Empty(t, results.Errors())
+	assert.Nil(t, api.Close())
+}
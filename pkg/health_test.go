@@ -0,0 +1,128 @@
+package openapiart_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+)
+
+// startHealthServer serves the mock grpc server along with the grpc health service at address
+func startHealthServer(t *testing.T, address string) (*grpc.Server, *health.Server) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return server, healthServer
+}
+
+// freeAddress returns a local address nothing is listening on
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestGrpcWaitUntilReady(t *testing.T) {
+	address := freeAddress(t)
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocation(address).SetHealthService("sanity.Openapi")
+	assert.Equal(t, "sanity.Openapi", transport.HealthService())
+
+	ready := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ready <- api.WaitUntilReady(ctx)
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	_, healthServer := startHealthServer(t, address)
+	healthServer.SetServingStatus("sanity.Openapi", healthpb.HealthCheckResponse_NOT_SERVING)
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case err := <-ready:
+		t.Fatalf("ready before the service is serving: %v", err)
+	default:
+	}
+
+	healthServer.SetServingStatus("sanity.Openapi", healthpb.HealthCheckResponse_SERVING)
+	assert.Nil(t, <-ready)
+	assert.Nil(t, getMetrics(api))
+}
+
+func TestGrpcWaitUntilReadyWithoutHealthService(t *testing.T) {
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocations([]string{unreachableLocation, grpcServer.Location})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, api.WaitUntilReady(ctx))
+	assert.Equal(t, grpcServer.Location, transport.ActiveLocation())
+}
+
+func TestHttpWaitUntilReady(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(httpServer.Location)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, api.WaitUntilReady(ctx))
+}
+
+func TestWaitUntilReadyTimeout(t *testing.T) {
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewGrpcTransport().SetLocation(unreachableLocation)
+	httpApi := openapiart.NewApi()
+	httpApi.NewHttpTransport().SetLocation("http://" + unreachableLocation)
+	for _, api := range []openapiart.Api{grpcApi, httpApi} {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		err := api.WaitUntilReady(ctx)
+		cancel()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "no location is ready")
+	}
+}
+
+func TestGrpcReconnect(t *testing.T) {
+	address := freeAddress(t)
+	server, _ := startHealthServer(t, address)
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocation(address)
+	assert.Equal(t, time.Second, transport.ReconnectTimeout())
+	assert.Nil(t, getMetrics(api))
+
+	// the server restarts, the requests fail while it is down
+	server.Stop()
+	for i := 0; i < 3; i++ {
+		assert.NotNil(t, getMetrics(api))
+	}
+	startHealthServer(t, address)
+	assert.Nil(t, getMetrics(api))
+}
+
+func TestGrpcKeepAlive(t *testing.T) {
+	params := keepalive.ClientParameters{Time: 10 * time.Second, Timeout: time.Second, PermitWithoutStream: true}
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetLocation(grpcServer.Location).SetKeepAlive(params)
+	assert.Equal(t, params, transport.KeepAlive())
+	assert.Nil(t, getMetrics(api))
+	assert.Nil(t, api.Close())
+}
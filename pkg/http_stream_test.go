@@ -0,0 +1,117 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadRecorder records how the body of the last request reached the mock http server
+type uploadRecorder struct {
+	mutex            sync.Mutex
+	transferEncoding []string
+	contentEncoding  string
+	responseEncoding string
+	contentLength    int64
+	body             []byte
+}
+
+func startUploadRecorder(t *testing.T) (*httptest.Server, *uploadRecorder) {
+	recorder := &uploadRecorder{}
+	router := NewMockHttpRouter()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		recorder.mutex.Lock()
+		recorder.transferEncoding = r.TransferEncoding
+		recorder.contentEncoding = r.Header.Get("Content-Encoding")
+		recorder.contentLength = r.ContentLength
+		recorder.body = body
+		recorder.mutex.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		router.ServeHTTP(w, r)
+		recorder.mutex.Lock()
+		recorder.responseEncoding = w.Header().Get("Content-Encoding")
+		recorder.mutex.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, recorder
+}
+
+func TestHttpStreamingUpload(t *testing.T) {
+	server, recorder := startUploadRecorder(t)
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming().SetStreamChunkBytes(64)
+	assert.Equal(t, uint64(64), transport.StreamChunkBytes())
+
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	_, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	expected, err := config.Marshal().ToJson()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chunked"}, recorder.transferEncoding)
+	assert.Equal(t, int64(-1), recorder.contentLength)
+	assert.Equal(t, expected, string(recorder.body))
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	_, err = api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chunked"}, recorder.transferEncoding)
+	assert.Equal(t, data, recorder.body)
+}
+
+func TestHttpStreamingOnlyClientStreams(t *testing.T) {
+	server, recorder := startUploadRecorder(t)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming()
+
+	// GetMetrics is not a client streaming operation
+	metReq := openapiart.NewMetricsRequest()
+	metReq.SetPort("p1")
+	_, err := api.GetMetrics(metReq)
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.transferEncoding)
+	assert.Equal(t, int64(len(recorder.body)), recorder.contentLength)
+}
+
+func TestHttpStreamingDisabled(t *testing.T) {
+	server, recorder := startUploadRecorder(t)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming().DisableHttpStreaming()
+
+	data := []byte("Hello123!!##$@")
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.transferEncoding)
+	assert.Equal(t, int64(len(data)), recorder.contentLength)
+	assert.Equal(t, data, recorder.body)
+}
+
+func TestHttpStreamingCompressedUpload(t *testing.T) {
+	server, recorder := startUploadRecorder(t)
+	progress := &progressRecorder{}
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming().SetStreamChunkBytes(1000).
+		SetCompression(openapiart.CompressionZstd).SetUploadProgress(progress.record)
+
+	// the body is compressed as it is streamed and the progress counts the uncompressed bytes
+	data := bytes.Repeat([]byte("0123456789"), 450)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chunked"}, recorder.transferEncoding)
+	assert.Equal(t, openapiart.CompressionZstd, recorder.contentEncoding)
+	assert.Equal(t, data, decompress(t, openapiart.CompressionZstd, recorder.body))
+	reported := progress.reported()
+	assert.Len(t, reported, 5)
+	assert.Equal(t, uint64(len(data)), reported[len(reported)-1].Sent)
+}
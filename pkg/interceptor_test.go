@@ -0,0 +1,153 @@
+package openapiart_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptorOrder(t *testing.T) {
+	for _, a := range newMockApis(nil) {
+		api := a.api
+		var calls []string
+		trace := func(name string) openapiart.Interceptor {
+			return func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+				calls = append(calls, name+" before "+invocation.Operation)
+				resp, err := next(ctx, invocation)
+				calls = append(calls, name+" after "+invocation.Operation)
+				return resp, err
+			}
+		}
+		api.Use(trace("first"), trace("second"))
+		api.Use(trace("third"))
+
+		_, err := api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"first before GetWarnings",
+			"second before GetWarnings",
+			"third before GetWarnings",
+			"third after GetWarnings",
+			"second after GetWarnings",
+			"first after GetWarnings",
+		}, calls)
+	}
+}
+
+func TestInterceptorInvocation(t *testing.T) {
+	for i, a := range newMockApis(nil) {
+		api := a.api
+		var invocations []openapiart.Invocation
+		var responses []interface{}
+		api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+			invocations = append(invocations, *invocation)
+			resp, err := next(ctx, invocation)
+			responses = append(responses, resp)
+			return resp, err
+		})
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		resp, err := api.SetConfig(config)
+		assert.Nil(t, err)
+		_, err = api.UploadConfig([]byte("Hello123!!##$@"))
+		assert.Nil(t, err)
+
+		transport := []string{"grpc", "http"}[i]
+		assert.Len(t, invocations, 2)
+		assert.Equal(t, openapiart.Invocation{Operation: "SetConfig", Request: config, Transport: transport}, invocations[0])
+		assert.Equal(t, openapiart.Invocation{Operation: "UploadConfig", Request: []byte("Hello123!!##$@"), Transport: transport}, invocations[1])
+		assert.Equal(t, resp, responses[0])
+		assert.Implements(t, (*openapiart.WarningDetails)(nil), responses[1])
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	api := openapiart.NewApi()
+	// nothing is listening on this location
+	api.NewGrpcTransport().SetLocation("127.0.0.1:1")
+	cached := openapiart.NewGetConfigResponse().PrefixConfig().SetA("cached")
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		if invocation.Operation == "GetConfig" {
+			return cached, nil
+		}
+		return next(ctx, invocation)
+	})
+	resp, err := api.GetConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "cached", resp.A())
+}
+
+func TestInterceptorFaultInjection(t *testing.T) {
+	for _, a := range newMockApis(nil) {
+		api := a.api
+		api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+			return nil, fmt.Errorf("injected fault for %s", invocation.Operation)
+		})
+		resp, err := api.GetWarnings()
+		assert.Nil(t, resp)
+		assert.NotNil(t, err)
+		assert.Equal(t, "injected fault for GetWarnings", err.Error())
+	}
+}
+
+func TestInterceptorUnexpectedResponse(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		return "not warnings", nil
+	})
+	resp, err := api.GetWarnings()
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "GetWarnings received response of type string")
+}
+
+func TestInterceptorReplacesRequest(t *testing.T) {
+	for _, a := range newMockApis(nil) {
+		api := a.api
+		replacement := NewFullyPopulatedPrefixConfig(api)
+		replacement.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+			return next(ctx, &openapiart.Invocation{Operation: invocation.Operation, Request: replacement, Transport: invocation.Transport})
+		})
+		// the request of the invocation is sent instead of the one passed to the method
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_400)
+		_, err := api.SetConfig(config)
+		assert.Nil(t, err)
+	}
+
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		invocation.Request = "not a config"
+		return next(ctx, invocation)
+	})
+	_, err := api.SetConfig(NewFullyPopulatedPrefixConfig(api))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "SetConfig received request of type string instead of PrefixConfig")
+}
+
+func TestInterceptorUseConcurrently(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+				return next(ctx, invocation)
+			})
+		}
+	}()
+	// the chain can be extended while operations are invoked
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, getMetrics(api))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,76 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// loopbackApis returns a grpc and an http api connected in memory to the mock servers
+func loopbackApis(t *testing.T) []openapiart.Api {
+	server := grpc.NewServer()
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	t.Cleanup(server.Stop)
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewLoopbackTransport().SetGrpcServer(server)
+	httpApi := openapiart.NewApi()
+	httpApi.NewLoopbackTransport().SetHttpHandler(NewMockHttpRouter())
+	return []openapiart.Api{grpcApi, httpApi}
+}
+
+func TestLoopbackTransport(t *testing.T) {
+	for _, api := range loopbackApis(t) {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		_, err := api.SetConfig(config)
+		assert.Nil(t, err)
+
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_400)
+		_, err = api.SetConfig(config)
+		assert.NotNil(t, err)
+
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		metrics, err := api.GetMetrics(metReq)
+		assert.Nil(t, err)
+		assert.NotNil(t, metrics)
+
+		reader, err := api.GetCaptureStream(context.Background())
+		assert.Nil(t, err)
+		capture, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, capture)
+		assert.Nil(t, reader.Close())
+
+		assert.Nil(t, api.Close())
+		assert.Nil(t, api.Close())
+	}
+}
+
+func TestLoopbackTransportOptions(t *testing.T) {
+	server := grpc.NewServer()
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	t.Cleanup(server.Stop)
+	api := openapiart.NewApi()
+	api.NewLoopbackTransport().SetGrpcServer(server).EnableGrpcStreaming().SetStreamChunkSize(1).SetCompression(openapiart.CompressionZstd)
+	data := bytes.Repeat([]byte("a"), 3*megabyte)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+	assert.Nil(t, api.Close())
+
+	server2, recorder := startUploadRecorder(t)
+	api = openapiart.NewApi()
+	api.NewLoopbackTransport().SetHttpHandler(server2.Config.Handler).EnableHttpStreaming().SetCompression(openapiart.CompressionGzip)
+	_, err = api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chunked"}, recorder.transferEncoding)
+	assert.Equal(t, openapiart.CompressionGzip, recorder.contentEncoding)
+	assert.Nil(t, api.Close())
+}
@@ -0,0 +1,209 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// otlpMetricsReceiver collects the names of the exported metrics
+type otlpMetricsReceiver struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	mutex   sync.Mutex
+	metrics []string
+}
+
+func (r *otlpMetricsReceiver) Export(ctx context.Context, request *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, resourceMetrics := range request.ResourceMetrics {
+		for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+			for _, metric := range scopeMetrics.Metrics {
+				r.metrics = append(r.metrics, metric.Name)
+			}
+		}
+	}
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (r *otlpMetricsReceiver) received() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.metrics...)
+}
+
+// meteredApi returns an Api recording its metrics with a meter provider read by the returned reader
+func meteredApi(t *testing.T) (openapiart.Api, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	api := openapiart.NewApi()
+	api.Telemetry().SetMeterProvider(provider)
+	return api, reader
+}
+
+// metricPoints returns the count of the histogram or the sum of the counter named name
+// for every set of attributes it has been recorded with
+func metricPoints(t *testing.T, reader *sdkmetric.ManualReader, name string) map[string]int64 {
+	data := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatal(err)
+	}
+	points := map[string]int64{}
+	for _, scopeMetrics := range data.ScopeMetrics {
+		for _, metric := range scopeMetrics.Metrics {
+			if metric.Name != name {
+				continue
+			}
+			switch values := metric.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for _, point := range values.DataPoints {
+					points[point.Attributes.Encoded(attribute.DefaultEncoder())] = int64(point.Count)
+				}
+			case metricdata.Histogram[int64]:
+				for _, point := range values.DataPoints {
+					points[point.Attributes.Encoded(attribute.DefaultEncoder())] = int64(point.Count)
+				}
+			case metricdata.Sum[int64]:
+				for _, point := range values.DataPoints {
+					points[point.Attributes.Encoded(attribute.DefaultEncoder())] = point.Value
+				}
+			}
+		}
+	}
+	return points
+}
+
+// labels returns the attributes of an operation sent with transport along with extra ones
+func labels(operation string, transport string, extra ...attribute.KeyValue) string {
+	kvs := append([]attribute.KeyValue{
+		attribute.String("operation", operation),
+		attribute.String("transport", transport),
+	}, extra...)
+	set := attribute.NewSet(kvs...)
+	return set.Encoded(attribute.DefaultEncoder())
+}
+
+func TestMetricsGrpc(t *testing.T) {
+	api, reader := meteredApi(t)
+	assert.NotNil(t, api.Telemetry().MeterProvider())
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	assert.Nil(t, getMetrics(api))
+	assert.Nil(t, getMetrics(api))
+
+	_, err := api.SetConfig(openapiart.NewPrefixConfig())
+	assert.NotNil(t, err)
+	// the mock server fails with the grpc code InvalidArgument
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_500)
+	_, err = api.SetConfig(config)
+	assert.NotNil(t, err)
+
+	duration := metricPoints(t, reader, "rpc.client.duration")
+	assert.Equal(t, int64(2), duration[labels("GetMetrics", "grpc")])
+	assert.Equal(t, int64(1), duration[labels("SetConfig", "grpc")])
+	assert.Equal(t, int64(2), metricPoints(t, reader, "rpc.client.request.size")[labels("GetMetrics", "grpc")])
+	assert.Equal(t, int64(2), metricPoints(t, reader, "rpc.client.response.size")[labels("GetMetrics", "grpc")])
+	assert.Equal(t, map[string]int64{
+		labels("SetConfig", "grpc", attribute.Int64("rpc.grpc.status_code", 3)): 1,
+	}, metricPoints(t, reader, "rpc.client.errors"))
+	assert.Equal(t, map[string]int64{
+		labels("SetConfig", "grpc"): 1,
+	}, metricPoints(t, reader, "rpc.client.validation.failures"))
+}
+
+func TestMetricsGrpcWrappedError(t *testing.T) {
+	api, reader := meteredApi(t)
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	// the grpc status code is found through the errors wrapping it
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		resp, err := next(ctx, invocation)
+		if err != nil {
+			return nil, fmt.Errorf("intercepted: %w", err)
+		}
+		return resp, nil
+	})
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_500)
+	_, err := api.SetConfig(config)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, map[string]int64{
+		labels("SetConfig", "grpc", attribute.Int64("rpc.grpc.status_code", 3)): 1,
+	}, metricPoints(t, reader, "rpc.client.errors"))
+}
+
+func TestMetricsHttp(t *testing.T) {
+	api, reader := meteredApi(t)
+	api.NewHttpTransport().SetLocation(httpServer.Location)
+	// the mock server answers the errors of its handler with the status 500
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_400)
+	_, err := api.SetConfig(config)
+	assert.NotNil(t, err)
+	warnings, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.NotNil(t, warnings)
+
+	assert.Equal(t, int64(1), metricPoints(t, reader, "rpc.client.duration")[labels("GetWarnings", "http")])
+	assert.Equal(t, int64(1), metricPoints(t, reader, "rpc.client.request.size")[labels("SetConfig", "http")])
+	assert.Equal(t, int64(1), metricPoints(t, reader, "rpc.client.response.size")[labels("GetWarnings", "http")])
+	assert.Equal(t, map[string]int64{
+		labels("SetConfig", "http", attribute.Int64("http.response.status_code", 500)): 1,
+	}, metricPoints(t, reader, "rpc.client.errors"))
+}
+
+func TestMetricsStreamChunks(t *testing.T) {
+	api, reader := meteredApi(t)
+	api.NewGrpcTransport().SetLocation(grpcServer.Location).EnableGrpcStreaming().SetStreamChunkSize(1)
+	data := bytes.Repeat([]byte("a"), 3*megabyte)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+
+	capture, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+	received, err := io.ReadAll(capture)
+	assert.Nil(t, err)
+	assert.Nil(t, capture.Close())
+
+	chunks := metricPoints(t, reader, "rpc.client.stream.chunks")
+	assert.Equal(t, int64(3), chunks[labels("UploadConfig", "grpc", attribute.String("direction", "sent"))])
+	assert.Equal(t, int64(len(expectedCapture())/len(captureChunk(0))), chunks[labels("GetCaptureStream", "grpc", attribute.String("direction", "received"))])
+	assert.Equal(t, expectedCapture(), received)
+}
+
+func TestMetricsOtlpExporter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := &otlpMetricsReceiver{}
+	collector := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(collector, &otlpReceiver{})
+	colmetricpb.RegisterMetricsServiceServer(collector, metrics)
+	go func() {
+		_ = collector.Serve(listener)
+	}()
+	t.Cleanup(collector.Stop)
+
+	// the metrics are exported to the collector of the spans
+	api := openapiart.NewApi()
+	api.Telemetry().SetGRPC().SetOtelCollector(listener.Addr().String())
+	tracedGetMetrics(t, api)
+	assert.Contains(t, metrics.received(), "rpc.client.duration")
+	assert.Contains(t, metrics.received(), "rpc.client.request.size")
+	assert.Contains(t, metrics.received(), "rpc.client.response.size")
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
@@ -14,6 +15,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
@@ -23,6 +25,50 @@ type GrpcServer struct {
 	Location string
 	Server   *grpc.Server
 	Config   *sanity.PrefixConfig
+	// metadata holds the metadata received with the last request
+	metadata metadata.MD
+	// deadline holds the deadline of the last request, zero when it has none
+	deadline time.Time
+	// upload holds the bytes of the last completed streamed upload
+	upload []byte
+	mutex  sync.Mutex
+}
+
+// uploadStore keeps the partially received streamed uploads so that clients can resume them
+var uploadStore = openapiart.NewUploadStore()
+
+func (s *GrpcServer) setUpload(upload []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.upload = upload
+}
+
+// Upload returns the bytes of the last completed streamed upload
+func (s *GrpcServer) Upload() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.upload
+}
+
+func (s *GrpcServer) setMetadata(ctx context.Context) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.metadata, _ = metadata.FromIncomingContext(ctx)
+	s.deadline, _ = ctx.Deadline()
+}
+
+// Deadline returns the deadline of the last request
+func (s *GrpcServer) Deadline() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deadline
+}
+
+// Metadata returns the metadata received with the last request
+func (s *GrpcServer) Metadata() metadata.MD {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.metadata
 }
 
 var (
@@ -43,7 +89,16 @@ func StartMockGrpcServer() error {
 		log.Fatalf("MockGrpcServer: Server failed to listen on address %s", grpcServer.Location)
 	}
 
-	grpcServer.Server = grpc.NewServer()
+	grpcServer.Server = grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			grpcServer.setMetadata(ctx)
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			grpcServer.setMetadata(ss.Context())
+			return handler(srv, ss)
+		}),
+	)
 	log.Printf("MockGrpcServer: Server started and listening on address %s", grpcServer.Location)
 
 	sanity.RegisterOpenapiServer(grpcServer.Server, &grpcServer)
@@ -100,7 +155,10 @@ func (s *GrpcServer) SetConfig(ctx context.Context, req *sanity.SetConfigRequest
 }
 
 func (s *GrpcServer) StreamSetConfig(srv sanity.Openapi_StreamSetConfigServer) error {
-	var blob []byte
+	upload, err := uploadStore.Resume(srv)
+	if err != nil {
+		return err
+	}
 	idx := 0
 	for {
 		data, err := srv.Recv()
@@ -110,6 +168,8 @@ func (s *GrpcServer) StreamSetConfig(srv sanity.Openapi_StreamSetConfigServer) e
 		}
 		if err != nil {
 			if err == io.EOF {
+				blob := upload.Complete()
+				s.setUpload(blob)
 				fmt.Printf("Transfer of %d bytes successful\n", len(blob))
 				// log.Println(string(blob))
 
@@ -128,7 +188,7 @@ func (s *GrpcServer) StreamSetConfig(srv sanity.Openapi_StreamSetConfigServer) e
 			return err
 		}
 		idx++
-		blob = append(blob, data.Datum...)
+		upload.Append(data.Datum)
 
 	}
 }
@@ -340,6 +400,32 @@ func (s *GrpcServer) StreamGetMetrics(req *sanity.GetMetricsRequest, srv sanity.
 
 }
 
+// captureChunks is the number of chunks sent by StreamGetCapture
+const captureChunks = 100
+
+func captureChunk(i int) []byte {
+	return []byte(fmt.Sprintf("chunk %03d;", i))
+}
+
+func (s *GrpcServer) GetCapture(ctx context.Context, req *empty.Empty) (*sanity.GetCaptureResponse, error) {
+	var bytes []byte
+	for i := 0; i < captureChunks; i++ {
+		bytes = append(bytes, captureChunk(i)...)
+	}
+	return &sanity.GetCaptureResponse{ResponseBytes: bytes}, nil
+}
+
+func (s *GrpcServer) StreamGetCapture(req *empty.Empty, srv sanity.Openapi_StreamGetCaptureServer) error {
+	for i := 0; i < captureChunks; i++ {
+		if err := srv.Send(&sanity.Data{Datum: captureChunk(i)}); err != nil {
+			return err
+		}
+		// pace the chunks so that the client can abort the capture half way
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
 func (s *GrpcServer) GetWarnings(ctx context.Context, empty *empty.Empty) (*sanity.GetWarningsResponse, error) {
 	resp := &sanity.GetWarningsResponse{
 		WarningDetails: &sanity.WarningDetails{
@@ -379,7 +465,10 @@ func (s *GrpcServer) UploadConfig(ctx context.Context, req *sanity.UploadConfigR
 }
 
 func (s *GrpcServer) StreamUploadConfig(srv sanity.Openapi_StreamUploadConfigServer) error {
-	var blob []byte
+	upload, err := uploadStore.Resume(srv)
+	if err != nil {
+		return err
+	}
 	idx := 0
 	for {
 		data, err := srv.Recv()
@@ -389,8 +478,9 @@ func (s *GrpcServer) StreamUploadConfig(srv sanity.Openapi_StreamUploadConfigSer
 		}
 		if err != nil {
 			if err == io.EOF {
+				blob := upload.Complete()
+				s.setUpload(blob)
 				fmt.Printf("Transfer of %d bytes successful\n", len(blob))
-				log.Println(string(blob))
 
 				resp := &sanity.UploadConfigResponse{
 					WarningDetails: &sanity.WarningDetails{
@@ -403,7 +493,7 @@ func (s *GrpcServer) StreamUploadConfig(srv sanity.Openapi_StreamUploadConfigSer
 			return err
 		}
 		idx++
-		blob = append(blob, data.Datum...)
+		upload.Append(data.Datum)
 
 	}
 }
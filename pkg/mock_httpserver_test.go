@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
 
 	openapiart "github.com/open-traffic-generator/openapiart/pkg"
 	httpapi "github.com/open-traffic-generator/openapiart/pkg/httpapi"
@@ -18,6 +19,37 @@ type HttpServer struct {
 	serverLocation string
 	Location       string
 	Config         openapiart.PrefixConfig
+	// header holds the headers received with the last request
+	header http.Header
+	// upload holds the bytes of the last upload
+	upload []byte
+	mutex  sync.Mutex
+}
+
+func (s *HttpServer) setUpload(upload []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.upload = upload
+}
+
+// Upload returns the bytes of the last upload
+func (s *HttpServer) Upload() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.upload
+}
+
+func (s *HttpServer) setHeader(header http.Header) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.header = header.Clone()
+}
+
+// Header returns the headers received with the last request
+func (s *HttpServer) Header() http.Header {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.header
 }
 
 var (
@@ -28,7 +60,7 @@ var (
 
 // 	Add route and strat HTTP server
 
-func StartMockHttpServer() {
+func NewMockHttpRouter() http.Handler {
 	bundlerHandler := NewBundlerHandler()
 	metricsHandler := NewMetricsHandler()
 	capabilitiesHandler := NewCapabilitiesHandler()
@@ -38,6 +70,14 @@ func StartMockHttpServer() {
 		capabilitiesHandler.GetController(),
 	}
 	router := httpapi.AppendRoutes(nil, controllers...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpServer.setHeader(r.Header)
+		router.ServeHTTP(w, r)
+	})
+}
+
+func StartMockHttpServer() {
+	router := NewMockHttpRouter()
 	httpServer.Location = fmt.Sprintf("http://%s", httpServer.serverLocation)
 	go func() {
 		log.Println("Generated Http Server serving incoming HTTP requests on ", httpServer.serverLocation)
@@ -138,8 +178,8 @@ func (h *bundlerHandler) UploadConfig(r *http.Request) (openapiart.UploadConfigR
 		if readError != nil {
 			return nil, readError
 		}
-		fmt.Println(body)
-		fmt.Println(string(body))
+		httpServer.setUpload(body)
+		fmt.Printf("Transfer of %d bytes successful\n", len(body))
 	}
 	response := openapiart.NewUploadConfigResponse()
 	response.WarningDetails().SetWarnings([]string{"w11", "w22"})
@@ -0,0 +1,94 @@
+package openapiart_test
+
+import (
+	"context"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// payloadEvents returns the attributes of the payload events of the spans named name by event name
+func payloadEvents(spans openapiart.SpanSnapshots, name string) map[string]map[attribute.Key]attribute.Value {
+	events := map[string]map[attribute.Key]attribute.Value{}
+	for _, span := range spans {
+		if span.Name != name {
+			continue
+		}
+		for _, event := range span.Events {
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, attr := range event.Attributes {
+				attrs[attr.Key] = attr.Value
+			}
+			events[event.Name] = attrs
+		}
+	}
+	return events
+}
+
+func TestPayloadCapture(t *testing.T) {
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewGrpcTransport().SetLocation(grpcServer.Location)
+	httpApi := openapiart.NewApi()
+	httpApi.NewHttpTransport().SetLocation(httpServer.Location)
+	for _, api := range []openapiart.Api{grpcApi, httpApi} {
+		telemetry := api.Telemetry().SetInMemoryExporter().EnablePayloadCapture()
+		assert.True(t, telemetry.PayloadCapture())
+		assert.Equal(t, 4096, telemetry.PayloadByteBudget())
+		_, err := telemetry.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, getMetrics(api))
+		telemetry.Stop()
+
+		events := payloadEvents(telemetry.Spans(), "GetMetrics")
+		assert.Equal(t, `{"choice":"port","port":"p1"}`, events["REQUEST"]["payload"].AsString())
+		assert.False(t, events["REQUEST"]["payload.truncated"].AsBool())
+		assert.Contains(t, events["RESPONSE"]["payload"].AsString(), `"ports":[`)
+	}
+}
+
+func TestPayloadCaptureLimits(t *testing.T) {
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetInMemoryExporter().EnablePayloadCapture().
+		SetPayloadByteBudget(10).
+		SetRedactedFields([]string{"Port"})
+	assert.Equal(t, 10, telemetry.SetPayloadByteBudget(0).PayloadByteBudget())
+	tracedGetMetrics(t, api)
+
+	// the sensitive fields are redacted before the payload is truncated
+	request := payloadEvents(telemetry.Spans(), "GetMetrics")["REQUEST"]
+	assert.Equal(t, `{"choice":`, request["payload"].AsString())
+	assert.Equal(t, int64(len(`{"choice":"port","port":"[REDACTED]"}`)), request["payload.size"].AsInt64())
+	assert.True(t, request["payload.truncated"].AsBool())
+
+	// the payloads are captured for the given fraction of the calls only
+	telemetry.ResetSpans()
+	telemetry.SetPayloadSamplingRatio(0)
+	assert.Equal(t, 0.0, telemetry.PayloadSamplingRatio())
+	tracedGetMetrics(t, api)
+	assert.Contains(t, spanNames(telemetry), "GetMetrics")
+	assert.Empty(t, payloadEvents(telemetry.Spans(), "GetMetrics"))
+
+	telemetry.ResetSpans()
+	telemetry.SetPayloadSamplingRatio(1).DisablePayloadCapture()
+	tracedGetMetrics(t, api)
+	assert.Empty(t, payloadEvents(telemetry.Spans(), "GetMetrics"))
+}
+
+func TestPayloadCaptureInterceptedRequest(t *testing.T) {
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetInMemoryExporter().EnablePayloadCapture()
+	api.Use(func(ctx context.Context, invocation *openapiart.Invocation, next openapiart.Invoker) (interface{}, error) {
+		request := openapiart.NewMetricsRequest()
+		request.SetPort("p2")
+		return next(ctx, &openapiart.Invocation{Operation: invocation.Operation, Request: request, Transport: invocation.Transport})
+	})
+	tracedGetMetrics(t, api)
+
+	// the request sent by the interceptor is captured instead of the one passed to the method
+	events := payloadEvents(telemetry.Spans(), "GetMetrics")
+	assert.Equal(t, `{"choice":"port","port":"p2"}`, events["REQUEST"]["payload"].AsString())
+}
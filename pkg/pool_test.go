@@ -0,0 +1,124 @@
+package openapiart_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// connCounter records the connections opened and closed on a mock http server
+type connCounter struct {
+	mutex  sync.Mutex
+	opened int
+	open   map[net.Conn]bool
+}
+
+func (c *connCounter) track(conn net.Conn, state http.ConnState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch state {
+	case http.StateNew:
+		c.opened++
+		c.open[conn] = true
+	case http.StateClosed, http.StateHijacked:
+		delete(c.open, conn)
+	}
+}
+
+func (c *connCounter) counts() (int, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.opened, len(c.open)
+}
+
+// startCountingHttpServers returns a plain and a tls mock http server counting their connections
+func startCountingHttpServers(t *testing.T) ([]*httptest.Server, []*connCounter) {
+	var servers []*httptest.Server
+	var counters []*connCounter
+	for _, secure := range []bool{false, true} {
+		counter := &connCounter{open: map[net.Conn]bool{}}
+		server := httptest.NewUnstartedServer(NewMockHttpRouter())
+		server.Config.ConnState = counter.track
+		if secure {
+			server.StartTLS()
+		} else {
+			server.Start()
+		}
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+		counters = append(counters, counter)
+	}
+	return servers, counters
+}
+
+func TestHttpConnectionReuse(t *testing.T) {
+	servers, counters := startCountingHttpServers(t)
+	for i, server := range servers {
+		api := openapiart.NewApi()
+		api.NewHttpTransport().SetLocation(server.URL)
+		for j := 0; j < 10; j++ {
+			_, err := api.GetWarnings()
+			assert.Nil(t, err)
+		}
+		opened, _ := counters[i].counts()
+		assert.Equal(t, 1, opened)
+		assert.Nil(t, api.Close())
+	}
+}
+
+func TestHttpMaxConnsPerHost(t *testing.T) {
+	servers, counters := startCountingHttpServers(t)
+	for i, server := range servers {
+		api := openapiart.NewApi()
+		transport := api.NewHttpTransport().SetLocation(server.URL).SetMaxConnsPerHost(2)
+		assert.Equal(t, 2, transport.MaxConnsPerHost())
+		callConcurrently(func(int) {
+			_, err := api.GetWarnings()
+			assert.Nil(t, err)
+		})
+		opened, _ := counters[i].counts()
+		assert.LessOrEqual(t, opened, 2)
+		assert.Nil(t, api.Close())
+	}
+}
+
+func TestHttpCloseDrainsPool(t *testing.T) {
+	servers, counters := startCountingHttpServers(t)
+	for i, server := range servers {
+		api := openapiart.NewApi()
+		api.NewHttpTransport().SetLocation(server.URL)
+		callConcurrently(func(int) {
+			_, err := api.GetWarnings()
+			assert.Nil(t, err)
+		})
+		_, open := counters[i].counts()
+		assert.Greater(t, open, 0)
+
+		assert.Nil(t, api.Close())
+		assert.Eventually(t, func() bool {
+			_, open := counters[i].counts()
+			return open == 0
+		}, 2*time.Second, 10*time.Millisecond)
+		// closing again has no effect
+		assert.Nil(t, api.Close())
+	}
+}
+
+func TestHttpIdleConnTimeout(t *testing.T) {
+	servers, counters := startCountingHttpServers(t)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(servers[0].URL).SetIdleConnTimeout(50 * time.Millisecond)
+	_, err := api.GetWarnings()
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		_, open := counters[0].counts()
+		return open == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Nil(t, api.Close())
+}
@@ -0,0 +1,181 @@
+package openapiart_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyServers fails the first failures requests received by its grpc and http servers
+type flakyServers struct {
+	failures int
+	attempts int
+	grpc     *grpc.Server
+	http     *httptest.Server
+	location string
+}
+
+func startFlakyServers(t *testing.T) *flakyServers {
+	f := &flakyServers{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.location = lis.Addr().String()
+	f.grpc = grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		f.attempts++
+		if f.attempts <= f.failures {
+			return nil, status.Error(codes.Unavailable, "server is restarting")
+		}
+		return handler(ctx, req)
+	}))
+	sanity.RegisterOpenapiServer(f.grpc, &grpcServer)
+	go func() {
+		_ = f.grpc.Serve(lis)
+	}()
+	router := NewMockHttpRouter()
+	f.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.attempts++
+		if f.attempts <= f.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		f.grpc.Stop()
+		f.http.Close()
+	})
+	return f
+}
+
+// apis returns a grpc and a http api talking to the flaky servers using policy
+func (f *flakyServers) apis(policy openapiart.RetryPolicy) []openapiart.Api {
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewGrpcTransport().SetLocation(f.location).SetRetryPolicy(policy)
+	httpApi := openapiart.NewApi()
+	httpApi.NewHttpTransport().SetLocation(f.http.URL).SetRetryPolicy(policy)
+	return []openapiart.Api{grpcApi, httpApi}
+}
+
+func (f *flakyServers) reset(failures int) {
+	f.failures = failures
+	f.attempts = 0
+}
+
+func TestRetryIdempotentOperation(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetInitialBackoff(time.Millisecond)
+	for _, api := range f.apis(policy) {
+		f.reset(2)
+		_, err := api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, f.attempts)
+	}
+}
+
+func TestRetryNonIdempotentOperation(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetInitialBackoff(time.Millisecond)
+	for _, api := range f.apis(policy) {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+
+		f.reset(1)
+		_, err := api.SetConfig(config)
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, f.attempts)
+	}
+
+	policy.SetIdempotentOperations("SetConfig")
+	for _, api := range f.apis(policy) {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+
+		f.reset(1)
+		_, err := api.SetConfig(config)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, f.attempts)
+	}
+}
+
+func TestRetryAttemptsExhausted(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetMaxAttempts(2).SetInitialBackoff(time.Millisecond)
+	for _, api := range f.apis(policy) {
+		f.reset(5)
+		_, err := api.GetWarnings()
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, f.attempts)
+	}
+}
+
+func TestRetryUnretryableError(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetInitialBackoff(time.Millisecond).SetRetryableCodes().SetRetryableStatuses()
+	for _, api := range f.apis(policy) {
+		f.reset(2)
+		_, err := api.GetWarnings()
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, f.attempts)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetInitialBackoff(time.Hour)
+	for _, api := range f.apis(policy) {
+		f.reset(5)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		_, err := api.GetWarningsCtx(ctx)
+		cancel()
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, f.attempts)
+		assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+	}
+}
+
+// customRetryPolicy is a RetryPolicy implemented outside of the sdk
+type customRetryPolicy struct {
+	openapiart.RetryPolicy
+}
+
+func (p customRetryPolicy) MaxAttempts() int {
+	return 2
+}
+
+func TestRetryCustomPolicy(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := customRetryPolicy{openapiart.NewRetryPolicy().SetInitialBackoff(time.Millisecond)}
+	for _, api := range f.apis(policy) {
+		f.reset(5)
+		_, err := api.GetWarnings()
+		assert.NotNil(t, err)
+		assert.Equal(t, 2, f.attempts)
+	}
+}
+
+func TestRetryPolicyCopied(t *testing.T) {
+	f := startFlakyServers(t)
+	policy := openapiart.NewRetryPolicy().SetInitialBackoff(time.Millisecond)
+	apis := f.apis(policy)
+	// changing the policy once set has no effect on the transports
+	policy.SetMaxAttempts(1)
+	for _, api := range apis {
+		f.reset(2)
+		_, err := api.GetWarnings()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, f.attempts)
+	}
+}
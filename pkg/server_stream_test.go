@@ -0,0 +1,114 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func expectedCapture() []byte {
+	var capture []byte
+	for i := 0; i < captureChunks; i++ {
+		capture = append(capture, captureChunk(i)...)
+	}
+	return capture
+}
+
+func TestGetCaptureStream(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	reader, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+
+	// the chunks are yielded as they are received
+	buf := make([]byte, 64)
+	n, err := reader.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, captureChunk(0), buf[:n])
+
+	rest, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedCapture(), append(buf[:n], rest...))
+	assert.Nil(t, reader.Close())
+
+	// the existing method reassembles the same stream
+	capture, err := streamApi.GetCapture()
+	assert.Nil(t, err)
+	assert.Equal(t, expectedCapture(), capture)
+}
+
+func TestGetCaptureStreamClose(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	reader, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+	buf := make([]byte, 64)
+	_, err = reader.Read(buf)
+	assert.Nil(t, err)
+
+	assert.Nil(t, reader.Close())
+	_, err = reader.Read(buf)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+}
+
+func TestGetCaptureStreamCancel(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, err := api.GetCaptureStream(ctx)
+	assert.Nil(t, err)
+	defer reader.Close()
+	buf := make([]byte, 64)
+	_, err = reader.Read(buf)
+	assert.Nil(t, err)
+
+	cancel()
+	received, err := io.ReadAll(reader)
+	assert.NotNil(t, err)
+	assert.Less(t, len(received), len(expectedCapture()))
+	assert.Contains(t, err.Error(), "context canceled")
+}
+
+func TestGetMetricsStream(t *testing.T) {
+	for i, api := range []openapiart.Api{apis[0], apis[1]} {
+		metReq := openapiart.NewMetricsRequest()
+		metReq.SetPort("p1")
+		reader, err := api.GetMetricsStream(context.Background(), metReq)
+		assert.Nil(t, err)
+		encoded, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Nil(t, reader.Close())
+
+		metrics := openapiart.NewMetrics()
+		if i == 0 {
+			// the grpc stream yields the protobuf encoding
+			msg := &sanity.Metrics{}
+			assert.Nil(t, proto.Unmarshal(encoded, msg))
+			_, err = metrics.Unmarshal().FromProto(msg)
+		} else {
+			// the http stream yields the json encoding
+			err = metrics.Unmarshal().FromJson(string(encoded))
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, openapiart.MetricsChoice.PORTS, metrics.Choice())
+		assert.Len(t, metrics.Ports().Items(), 2)
+	}
+}
+
+func TestGetCaptureStreamHttp(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(httpServer.Location)
+	reader, err := api.GetCaptureStream(context.Background())
+	assert.Nil(t, err)
+	capture, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Close())
+	assert.True(t, bytes.Equal([]byte("Successful set config operation"), capture))
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/open-traffic-generator/openapiart/pkg/httpapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func zstdCompress(t *testing.T, data []byte) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll(data, nil)
+}
+
+func TestPostRootResponseCompressed(t *testing.T) {
+	router := setup()
+	inputbody := openapiart.NewApiTestInputBody().SetSomeString("this is the compressed input body")
+	j, _ := inputbody.Marshal().ToJson()
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/apitest", bytes.NewReader(zstdCompress(t, []byte(j))))
+	req.Header.Set("Content-Encoding", "zstd")
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Equal(t, "gzip", wr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", wr.Header().Get("Vary"))
+
+	reader, err := gzip.NewReader(wr.Body)
+	assert.Nil(t, err)
+	jsonResponse, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	r := openapiart.NewCommonResponseSuccess()
+	err = r.Unmarshal().FromJson(string(jsonResponse))
+	assert.Nil(t, err)
+	assert.Equal(t, "this is the compressed input body", r.Message())
+}
+
+func TestGetRootResponseCompressionRefused(t *testing.T) {
+	router := setup()
+	req, _ := http.NewRequest(http.MethodGet, "/api/apitest", nil)
+	req.Header.Set("Accept-Encoding", "zstd;q=0, gzip; q=0.0, identity")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusOK, wr.Code)
+	assert.Empty(t, wr.Header().Get("Content-Encoding"))
+
+	r := openapiart.NewCommonResponseSuccess()
+	err := r.Unmarshal().FromJson(wr.Body.String())
+	assert.Nil(t, err)
+	assert.Equal(t, "from GetRootResponse", r.Message())
+}
+
+func TestPostRootResponseUnsupportedEncoding(t *testing.T) {
+	router := setup()
+	req, _ := http.NewRequest(http.MethodPost, "/api/apitest", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Encoding", "br")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, wr.Code)
+
+	req, _ = http.NewRequest(http.MethodPost, "/api/apitest", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Encoding", "gzip")
+	wr = httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusBadRequest, wr.Code)
+}
+
+func TestPostRootResponseDecompressedTooLarge(t *testing.T) {
+	previous := httpapi.MaxDecompressedBodyBytes
+	httpapi.MaxDecompressedBodyBytes = 1000
+	t.Cleanup(func() { httpapi.MaxDecompressedBodyBytes = previous })
+
+	inputbody := openapiart.NewApiTestInputBody().SetSomeString(string(bytes.Repeat([]byte("a"), 2000)))
+	j, _ := inputbody.Marshal().ToJson()
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	_, _ = writer.Write([]byte(j))
+	_ = writer.Close()
+
+	router := setup()
+	for encoding, body := range map[string][]byte{"gzip": gzipped.Bytes(), "zstd": zstdCompress(t, []byte(j))} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/apitest", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		wr := httptest.NewRecorder()
+		router.ServeHTTP(wr, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, wr.Code, encoding)
+	}
+
+	// a limit <= 0 lets any body be decompressed
+	httpapi.MaxDecompressedBodyBytes = 0
+	req, _ := http.NewRequest(http.MethodPost, "/api/apitest", bytes.NewReader(gzipped.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusOK, wr.Code)
+}
@@ -1,8 +1,10 @@
 package test
 
 import (
+	"bufio"
 	"bytes"
 	"io"
+	"net"
 
 	"net/http"
 	"net/http/httptest"
@@ -61,3 +63,49 @@ func TestDummyResponseTest(t *testing.T) {
 	assert.Equal(t, "\"this is a string response\"", string(response))
 
 }
+
+func TestPostRootResponseChunked(t *testing.T) {
+	server := httptest.NewServer(setup())
+	defer server.Close()
+
+	inputbody := openapiart.NewApiTestInputBody().SetSomeString("this is a chunked input body")
+	j, _ := inputbody.Marshal().ToJson()
+	reader, writer := io.Pipe()
+	go func() {
+		// the body is written in pieces of unknown total length
+		for i := 0; i < len(j); i += 8 {
+			end := i + 8
+			if end > len(j) {
+				end = len(j)
+			}
+			_, _ = writer.Write([]byte(j[i:end]))
+		}
+		writer.Close()
+	}()
+	resp, err := http.Post(server.URL+"/api/apitest", "application/json", reader)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	jsonResponse, _ := io.ReadAll(resp.Body)
+	r := openapiart.NewCommonResponseSuccess()
+	err = r.Unmarshal().FromJson(string(jsonResponse))
+	assert.Nil(t, err)
+	assert.Equal(t, "this is a chunked input body", r.Message())
+}
+
+func TestPostRootResponseTruncatedChunks(t *testing.T) {
+	server := httptest.NewServer(setup())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	// the second chunk has an invalid size line
+	_, err = conn.Write([]byte("POST /api/apitest HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n2\r\n{\"\r\nzz\r\n"))
+	assert.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/open-traffic-generator/openapiart/pkg/httpapi"
+	"github.com/open-traffic-generator/openapiart/pkg/httpapi/controllers"
+	"github.com/open-traffic-generator/openapiart/pkg/httpapi/interfaces"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedApiTestHandler records the span context its handler methods are called with
+type tracedApiTestHandler struct {
+	interfaces.ApiTestHandler
+	spanContext trace.SpanContext
+}
+
+func (h *tracedApiTestHandler) GetController() interfaces.ApiTestController {
+	return controllers.NewHttpApiTestController(h)
+}
+
+func (h *tracedApiTestHandler) GetRootResponse(r *http.Request) (openapiart.GetRootResponseResponse, error) {
+	h.spanContext = trace.SpanContextFromContext(r.Context())
+	return h.ApiTestHandler.GetRootResponse(r)
+}
+
+// hijackController serves a route taking over the connection to write the response itself
+type hijackController struct{}
+
+func (hijackController) Routes() []httpapi.Route {
+	return []httpapi.Route{
+		{Path: "/hijack", Method: "GET", Name: "Hijack", Handler: func(w http.ResponseWriter, r *http.Request) {
+			conn, buffer, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer conn.Close()
+			_, _ = buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = buffer.Flush()
+		}},
+	}
+}
+
+// recordSpans sets a global tracer provider recording the spans until the end of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func TestServerSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	handler := &tracedApiTestHandler{ApiTestHandler: NewApiTestHandler()}
+	router := httpapi.AppendRoutes(nil, handler.GetController())
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/apitest", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusOK, wr.Code)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GetRootResponse", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.SpanContext().TraceID().String())
+	assert.Equal(t, "b7ad6b7169203331", span.Parent().SpanID().String())
+	assert.True(t, span.Parent().IsRemote())
+	assert.Contains(t, span.Attributes(), attribute.String("http.route", "/api/apitest"))
+	assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+
+	// the handler method is called with the server span
+	assert.Equal(t, span.SpanContext(), handler.spanContext)
+}
+
+func TestServerSpansWithoutTraceContext(t *testing.T) {
+	recorder := recordSpans(t)
+	router := setup()
+	req, _ := http.NewRequest(http.MethodGet, "/api/apitest", nil)
+	req.Header.Set("Content-Encoding", "br")
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, wr.Code)
+
+	// a request without trace context starts a new trace
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.False(t, spans[0].Parent().IsValid())
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusUnsupportedMediaType))
+}
+
+func TestServerSpansNamedAfterOperationId(t *testing.T) {
+	recorder := recordSpans(t)
+	router := setup()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/apitest", nil)
+	wr := httptest.NewRecorder()
+	router.ServeHTTP(wr, req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "dummyResponseTest", spans[0].Name())
+}
+
+func TestServerSpansHijackedConnection(t *testing.T) {
+	recorder := recordSpans(t)
+	server := httptest.NewServer(httpapi.AppendRoutes(nil, hijackController{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "hijacked", string(body))
+
+	// the routes without operationId are named after their Name
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "Hijack", spans[0].Name())
+}
@@ -0,0 +1,296 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpReceiver collects the exported spans along with the headers of the exports
+type otlpReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	mutex   sync.Mutex
+	spans   []string
+	headers []map[string]string
+}
+
+func (r *otlpReceiver) receive(request *coltracepb.ExportTraceServiceRequest, headers map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				r.spans = append(r.spans, span.Name)
+			}
+		}
+	}
+	r.headers = append(r.headers, headers)
+}
+
+func (r *otlpReceiver) received() ([]string, []map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.spans...), append([]map[string]string{}, r.headers...)
+}
+
+func (r *otlpReceiver) Export(ctx context.Context, request *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := map[string]string{}
+	for key, values := range md {
+		headers[key] = strings.Join(values, ",")
+	}
+	r.receive(request, headers)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// ServeHTTP receives the spans exported over http at /collector/traces
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/collector/traces" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = reader
+	}
+	data, err := io.ReadAll(body)
+	request := &coltracepb.ExportTraceServiceRequest{}
+	if err == nil {
+		err = proto.Unmarshal(data, request)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	headers := map[string]string{}
+	for key := range req.Header {
+		headers[strings.ToLower(key)] = req.Header.Get(key)
+	}
+	r.receive(request, headers)
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// tracedGetMetrics calls GetMetrics on the mock grpc server with telemetry started and stops it
+func tracedGetMetrics(t *testing.T, api openapiart.Api) {
+	telemetry, err := api.Telemetry().Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	assert.Nil(t, getMetrics(api))
+	telemetry.Stop()
+}
+
+func TestTelemetryHttpExporter(t *testing.T) {
+	pki := newTestPKI(t)
+	receiver := &otlpReceiver{}
+	collector := httptest.NewUnstartedServer(receiver)
+	collector.TLS = pki.serverTLSConfig(false)
+	collector.StartTLS()
+	t.Cleanup(collector.Close)
+
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetHTTP().SetOtelCollector(collector.Listener.Addr().String()).
+		SetTLSConfig(&tls.Config{RootCAs: pki.certPool}).
+		SetHeaders(map[string]string{"Authorization": "Bearer collector-token"}).
+		SetExporterTimeout(5 * time.Second).
+		SetExporterCompression(openapiart.CompressionGzip).
+		SetURLPath("/collector/traces")
+	assert.Equal(t, 5*time.Second, telemetry.ExporterTimeout())
+	assert.Equal(t, openapiart.CompressionGzip, telemetry.ExporterCompression())
+	assert.Equal(t, "/collector/traces", telemetry.URLPath())
+	assert.Equal(t, "Bearer collector-token", telemetry.Headers()["Authorization"])
+	assert.NotNil(t, telemetry.TLSConfig())
+	tracedGetMetrics(t, api)
+
+	spans, headers := receiver.received()
+	assert.Contains(t, spans, "GetMetrics")
+	assert.Equal(t, "Bearer collector-token", headers[0]["authorization"])
+	assert.Equal(t, "gzip", headers[0]["content-encoding"])
+}
+
+func TestTelemetryGrpcExporter(t *testing.T) {
+	pki := newTestPKI(t)
+	receiver := &otlpReceiver{}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compression := &compressionRecorder{received: true}
+	collector := grpc.NewServer(grpc.Creds(credentials.NewTLS(pki.serverTLSConfig(false))), grpc.StatsHandler(compression))
+	coltracepb.RegisterTraceServiceServer(collector, receiver)
+	colmetricpb.RegisterMetricsServiceServer(collector, &otlpMetricsReceiver{})
+	go func() {
+		_ = collector.Serve(listener)
+	}()
+	t.Cleanup(collector.Stop)
+
+	api := openapiart.NewApi()
+	api.Telemetry().SetGRPC().SetOtelCollector(listener.Addr().String()).
+		SetTLSConfig(&tls.Config{RootCAs: pki.certPool}).
+		SetHeaders(map[string]string{"x-api-key": "collector-key"}).
+		SetExporterCompression(openapiart.CompressionGzip)
+	tracedGetMetrics(t, api)
+
+	spans, headers := receiver.received()
+	assert.Contains(t, spans, "GetMetrics")
+	assert.Equal(t, "collector-key", headers[0]["x-api-key"])
+	// the spans and the metrics are exported
+	assert.Equal(t, []string{"gzip", "gzip"}, compression.recorded())
+}
+
+func TestTelemetryGrpcExporterInsecureToTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := grpc.NewServer(grpc.Creds(credentials.NewTLS(pki.serverTLSConfig(false))))
+	coltracepb.RegisterTraceServiceServer(collector, &otlpReceiver{})
+	go func() {
+		_ = collector.Serve(listener)
+	}()
+	t.Cleanup(collector.Stop)
+
+	// the collector requires tls, connecting fails within the exporter timeout
+	api := openapiart.NewApi()
+	api.Telemetry().SetGRPC().SetOtelCollector(listener.Addr().String()).SetExporterTimeout(300 * time.Millisecond)
+	start := time.Now()
+	_, err = api.Telemetry().Start()
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, openapiart.CompressionNone, api.Telemetry().SetExporterCompression(openapiart.CompressionZstd).ExporterCompression())
+}
+
+// spanNames returns the names of the spans kept in memory by telemetry
+func spanNames(telemetry openapiart.Telemetry) []string {
+	var names []string
+	for _, span := range telemetry.Spans() {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+func TestTelemetryInMemoryExporter(t *testing.T) {
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetInMemoryExporter()
+	assert.Equal(t, 1.0, telemetry.SamplingRatio())
+	assert.False(t, telemetry.ParentBasedSampling())
+	_, err := telemetry.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer telemetry.Stop()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location)
+	assert.Nil(t, getMetrics(api))
+
+	// the spans can be inspected as soon as they end
+	assert.Contains(t, spanNames(telemetry), "GetMetrics")
+	telemetry.ResetSpans()
+	assert.Empty(t, telemetry.Spans())
+}
+
+func TestTelemetrySampling(t *testing.T) {
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetInMemoryExporter().SetSamplingRatio(0)
+	assert.Equal(t, 0.0, telemetry.SamplingRatio())
+	assert.Equal(t, 0.0, telemetry.SetSamplingRatio(2).SamplingRatio())
+	tracedGetMetrics(t, api)
+	assert.Empty(t, telemetry.Spans())
+
+	// the spans of a sampled parent are sampled along with it, and only those
+	parent := trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, Remote: true}
+	for _, flags := range []trace.TraceFlags{0, trace.FlagsSampled} {
+		parent.TraceFlags = flags
+		ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(parent))
+		telemetry.SetSamplingRatio(1).EnableParentBasedSampling().SetRootContext(ctx)
+		tracedGetMetrics(t, api)
+		if flags.IsSampled() {
+			assert.Contains(t, spanNames(telemetry), "GetMetrics")
+			for _, span := range telemetry.Spans() {
+				assert.Equal(t, trace.TraceID{1}, span.SpanContext.TraceID())
+			}
+		} else {
+			assert.Empty(t, telemetry.Spans())
+		}
+	}
+}
+
+func TestTelemetryFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	api := openapiart.NewApi()
+	api.Telemetry().SetFileExporter(path)
+	tracedGetMetrics(t, api)
+	assert.Nil(t, api.Close())
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		span := map[string]interface{}{}
+		assert.Nil(t, decoder.Decode(&span))
+		names = append(names, span["Name"].(string))
+	}
+	assert.Contains(t, names, "GetMetrics")
+}
+
+func TestTelemetryServerSpans(t *testing.T) {
+	api := openapiart.NewApi()
+	telemetry := api.Telemetry().SetInMemoryExporter()
+	_, err := telemetry.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.NewHttpTransport().SetLocation(httpServer.Location)
+	assert.Nil(t, getMetrics(api))
+	telemetry.Stop()
+
+	// the span of the mock server, named after the operationId, belongs to the trace of the client
+	var client, server *openapiart.SpanSnapshot
+	spans := telemetry.Spans()
+	for i := range spans {
+		if spans[i].Name == "GetMetrics" && spans[i].SpanKind == trace.SpanKindClient {
+			client = &spans[i]
+		}
+		if spans[i].Name == "get_metrics" && spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	if client == nil || server == nil {
+		t.Fatalf("missing client or server span in %v", spanNames(telemetry))
+	}
+	assert.Equal(t, client.SpanContext.TraceID(), server.SpanContext.TraceID())
+	assert.True(t, server.Parent.IsRemote())
+}
@@ -0,0 +1,339 @@
+package openapiart_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testPKI holds a locally generated CA along with a server and a client
+// certificate signed by it, all of them written as PEM files to dir
+type testPKI struct {
+	dir        string
+	caFile     string
+	certPool   *x509.CertPool
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writeTestPem(t *testing.T, dir string, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(keyFile, keyPem, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir := t.TempDir()
+	now := time.Now()
+	ca, caKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "openapiart test ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	server, serverKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	client, clientKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "openapiart test client"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	pki := &testPKI{dir: dir, certPool: x509.NewCertPool()}
+	pki.certPool.AddCert(ca)
+	pki.caFile, _ = writeTestPem(t, dir, "ca", ca, nil)
+	serverCertFile, serverKeyFile := writeTestPem(t, dir, "server", server, serverKey)
+	serverCert, err := tls.LoadX509KeyPair(serverCertFile, serverKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki.serverCert = serverCert
+	pki.clientCert, pki.clientKey = writeTestPem(t, dir, "client", client, clientKey)
+	return pki
+}
+
+// serverTLSConfig returns the tls configuration of a mock server,
+// client certificates are verified against the test CA when mutual is set
+func (pki *testPKI) serverTLSConfig(mutual bool) *tls.Config {
+	config := &tls.Config{Certificates: []tls.Certificate{pki.serverCert}}
+	if mutual {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = pki.certPool
+	}
+	return config
+}
+
+func startTLSMockGrpcServer(t *testing.T, config *tls.Config) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestGrpcTLSCACertFile(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockGrpcServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(location).SetCACertFile(pki.caFile).SetServerName("localhost")
+	defer api.Close()
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestGrpcMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockGrpcServer(t, pki.serverTLSConfig(true))
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().
+		SetLocation(location).
+		SetCACertFile(pki.caFile).
+		SetClientCertificate(pki.clientCert, pki.clientKey).
+		SetServerName("localhost")
+	defer api.Close()
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestGrpcTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockGrpcServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(location).SetTLSConfig(&tls.Config{
+		RootCAs:    pki.certPool,
+		ServerName: "localhost",
+		MinVersion: tls.VersionTLS12,
+	})
+	defer api.Close()
+	_, err := api.GetConfig()
+	assert.Nil(t, err)
+}
+
+func TestGrpcTLSUnknownAuthority(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockGrpcServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(location).SetServerName("localhost").SetDialTimeout(2 * time.Second)
+	defer api.Close()
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tls connection to "+location+" failed")
+	assert.Contains(t, err.Error(), "certificate")
+}
+
+func TestGrpcTLSMissingClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockGrpcServer(t, pki.serverTLSConfig(true))
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(location).SetCACertFile(pki.caFile).SetServerName("localhost").SetDialTimeout(2 * time.Second)
+	defer api.Close()
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+}
+
+func TestGrpcTLSInvalidFiles(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation("127.0.0.1:1").SetCACertFile(filepath.Join(t.TempDir(), "missing.crt"))
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "could not read CA certificate file")
+
+	api.NewGrpcTransport().SetLocation("127.0.0.1:1").SetClientCertificate("missing.crt", "missing.key")
+	_, err = api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "could not load client certificate")
+}
+
+func startTLSMockHttpServer(t *testing.T, config *tls.Config) string {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: NewMockHttpRouter()}
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	return "https://" + lis.Addr().String()
+}
+
+func TestHttpTLSCACertFile(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockHttpServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetCACertFile(pki.caFile)
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestHttpMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockHttpServer(t, pki.serverTLSConfig(true))
+	api := openapiart.NewApi()
+	api.NewHttpTransport().
+		SetLocation(location).
+		SetCACertFile(pki.caFile).
+		SetClientCertificate(pki.clientCert, pki.clientKey).
+		SetMinTLSVersion(tls.VersionTLS12)
+	config := NewFullyPopulatedPrefixConfig(api)
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	resp, err := api.SetConfig(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestHttpTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockHttpServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetTLSConfig(&tls.Config{RootCAs: pki.certPool})
+	_, err := api.GetConfig()
+	assert.Nil(t, err)
+}
+
+func TestHttpTLSServerName(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockHttpServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetCACertFile(pki.caFile).SetServerName("localhost")
+	_, err := api.GetConfig()
+	assert.Nil(t, err)
+
+	api = openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetCACertFile(pki.caFile).SetServerName("controller.lab")
+	_, err = api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tls handshake with")
+}
+
+func TestHttpTLSUnknownAuthority(t *testing.T) {
+	pki := newTestPKI(t)
+	location := startTLSMockHttpServer(t, pki.serverTLSConfig(false))
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetVerify(true)
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tls handshake with")
+	assert.Contains(t, err.Error(), "certificate")
+
+	// verification is skipped by default
+	api = openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location)
+	_, err = api.GetConfig()
+	assert.Nil(t, err)
+
+	// setting a tls option does not downgrade the verification
+	api = openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetServerName("localhost")
+	_, err = api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+
+	api = openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetServerName("localhost").SetVerify(false)
+	_, err = api.GetConfig()
+	assert.Nil(t, err)
+}
+
+func TestHttpTLSMinVersion(t *testing.T) {
+	pki := newTestPKI(t)
+	config := pki.serverTLSConfig(false)
+	config.MaxVersion = tls.VersionTLS12
+	location := startTLSMockHttpServer(t, config)
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(location).SetCACertFile(pki.caFile).SetMinTLSVersion(tls.VersionTLS13)
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tls handshake with")
+}
+
+func TestHttpTLSInvalidFiles(t *testing.T) {
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation("https://127.0.0.1:1").SetCACertFile(filepath.Join(t.TempDir(), "missing.crt"))
+	_, err := api.GetConfig()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "could not read CA certificate file")
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os/exec"
 	"strings"
 	"testing"
 	"time"
 
 	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	sanity "github.com/open-traffic-generator/openapiart/pkg/sanity"
 
 	"runtime"
 
@@ -573,3 +577,146 @@ func TestHttpUploadConfigSuccess(t *testing.T) {
 	assert.Equal(t, len(warn.Warnings()), 2)
 	assert.Equal(t, warn.Warnings()[1], "w22")
 }
+
+func TestSetConfigCtxSuccess(t *testing.T) {
+	for _, api := range apis {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Minute)
+		resp, err := api.SetConfigCtx(ctx, config)
+		cancelFunc()
+		assert.Nil(t, err)
+		assert.NotNil(t, resp)
+	}
+
+	// the grpc server receives the deadline of the context instead of the request timeout
+	config := NewFullyPopulatedPrefixConfig(apis[0])
+	config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancelFunc := context.WithDeadline(context.Background(), deadline)
+	defer cancelFunc()
+	_, err := apis[0].SetConfigCtx(ctx, config)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, deadline, grpcServer.Deadline(), time.Second)
+}
+
+// blockedRequest holds the deadline and the error of the context of a request received by a blocking server
+type blockedRequest struct {
+	deadline time.Time
+	err      error
+}
+
+// startBlockingServers starts a grpc and a http server answering no request until it is cancelled,
+// every request they receive is sent to the returned channel once cancelled
+func startBlockingServers(t *testing.T) (openapiart.Api, openapiart.Api, chan blockedRequest) {
+	cancelled := make(chan blockedRequest, 2)
+	stopped := make(chan struct{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			deadline, _ := ctx.Deadline()
+			cancelled <- blockedRequest{deadline: deadline, err: ctx.Err()}
+		case <-stopped:
+		}
+		return nil, ctx.Err()
+	}))
+	sanity.RegisterOpenapiServer(server, &grpcServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server notices a cancelled request only once its body has been read
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			cancelled <- blockedRequest{err: r.Context().Err()}
+		case <-stopped:
+		}
+	}))
+	t.Cleanup(func() {
+		close(stopped)
+		server.Stop()
+		blocking.Close()
+	})
+	grpcApi := openapiart.NewApi()
+	grpcApi.NewGrpcTransport().SetLocation(listener.Addr().String())
+	httpApi := openapiart.NewApi()
+	httpApi.NewHttpTransport().SetLocation(blocking.URL)
+	return grpcApi, httpApi, cancelled
+}
+
+// serverRequest returns the request received by a blocking server once it is cancelled
+func serverRequest(t *testing.T, cancelled chan blockedRequest) blockedRequest {
+	select {
+	case req := <-cancelled:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request has not been cancelled on the server")
+		return blockedRequest{}
+	}
+}
+
+func TestSetConfigCtxCancelled(t *testing.T) {
+	for _, api := range apis {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		ctx, cancelFunc := context.WithCancel(context.Background())
+		cancelFunc()
+		resp, err := api.SetConfigCtx(ctx, config)
+		assert.Nil(t, resp)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "context canceled")
+	}
+
+	// a request in flight is cancelled on the server along with the context
+	grpcApi, httpApi, cancelled := startBlockingServers(t)
+	for _, api := range []openapiart.Api{grpcApi, httpApi} {
+		config := NewFullyPopulatedPrefixConfig(api)
+		config.SetResponse(openapiart.PrefixConfigResponse.STATUS_200)
+		ctx, cancelFunc := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancelFunc)
+		_, err := api.SetConfigCtx(ctx, config)
+		assert.NotNil(t, err)
+		assert.Equal(t, context.Canceled, serverRequest(t, cancelled).err)
+	}
+}
+
+func TestGetConfigCtxDeadlineExceeded(t *testing.T) {
+	for _, api := range apis {
+		ctx, cancelFunc := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		resp, err := api.GetConfigCtx(ctx)
+		cancelFunc()
+		assert.Nil(t, resp)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "deadline exceeded")
+	}
+
+	// the grpc server receives the deadline of the context, the http one sees the request cancelled
+	grpcApi, httpApi, cancelled := startBlockingServers(t)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	deadline, _ := ctx.Deadline()
+	_, err := grpcApi.GetConfigCtx(ctx)
+	cancelFunc()
+	assert.NotNil(t, err)
+	req := serverRequest(t, cancelled)
+	assert.NotNil(t, req.err)
+	assert.WithinDuration(t, deadline, req.deadline, time.Second)
+
+	ctx, cancelFunc = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	_, err = httpApi.GetConfigCtx(ctx)
+	cancelFunc()
+	assert.NotNil(t, err)
+	assert.Equal(t, context.Canceled, serverRequest(t, cancelled).err)
+}
+
+func TestUploadConfigCtxSuccess(t *testing.T) {
+	for _, api := range []openapiart.Api{apis[0], apis[1], streamApi} {
+		warn, err := api.UploadConfigCtx(context.Background(), []byte("Hello123!!##$@"))
+		assert.Nil(t, err)
+		assert.NotNil(t, warn)
+	}
+}
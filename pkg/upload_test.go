@@ -0,0 +1,308 @@
+package openapiart_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openapiart "github.com/open-traffic-generator/openapiart/pkg"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const megabyte = 1024 * 1024
+
+// progressRecorder collects the upload progress reported by an api
+type progressRecorder struct {
+	mutex    sync.Mutex
+	progress []openapiart.UploadProgress
+}
+
+func (r *progressRecorder) record(progress openapiart.UploadProgress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.progress = append(r.progress, progress)
+}
+
+func (r *progressRecorder) reported() []openapiart.UploadProgress {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]openapiart.UploadProgress{}, r.progress...)
+}
+
+// interruptingStream fails the first stream it wraps with codes.Unavailable once failAfter chunks were sent
+type interruptingStream struct {
+	grpc.ClientStream
+	failAfter int
+	sent      int
+}
+
+func (s *interruptingStream) SendMsg(m interface{}) error {
+	if s.sent == s.failAfter {
+		// give the server the time to receive the chunks sent before the failure
+		time.Sleep(100 * time.Millisecond)
+		return status.Error(codes.Unavailable, "connection reset")
+	}
+	s.sent++
+	return s.ClientStream.SendMsg(m)
+}
+
+// interruptingApi returns a grpc streaming api and its transport, the first streamed upload fails after failAfter chunks
+func interruptingApi(t *testing.T, failAfter int) (openapiart.Api, openapiart.GrpcTransport) {
+	var mutex sync.Mutex
+	interrupted := false
+	conn, err := grpc.Dial(grpcServer.Location,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			stream, err := streamer(ctx, desc, cc, method, opts...)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil || interrupted {
+				return stream, err
+			}
+			interrupted = true
+			return &interruptingStream{ClientStream: stream, failAfter: failAfter}, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	api := openapiart.NewApi()
+	transport := api.NewGrpcTransport().SetClientConnection(conn).EnableGrpcStreaming().SetStreamChunkSize(1)
+	return api, transport
+}
+
+func TestGrpcUploadProgress(t *testing.T) {
+	recorder := &progressRecorder{}
+	api := openapiart.NewApi()
+	api.NewGrpcTransport().SetLocation(grpcServer.Location).EnableGrpcStreaming().SetStreamChunkSize(1).SetUploadProgress(recorder.record)
+
+	data := bytes.Repeat([]byte("a"), 3*megabyte+megabyte/2)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+	assert.Equal(t, []openapiart.UploadProgress{
+		{Operation: "UploadConfig", Sent: 1 * megabyte, Total: uint64(len(data)), Chunk: 0},
+		{Operation: "UploadConfig", Sent: 2 * megabyte, Total: uint64(len(data)), Chunk: 1},
+		{Operation: "UploadConfig", Sent: 3 * megabyte, Total: uint64(len(data)), Chunk: 2},
+		{Operation: "UploadConfig", Sent: uint64(len(data)), Total: uint64(len(data)), Chunk: 3},
+	}, recorder.reported())
+}
+
+func TestHttpUploadProgress(t *testing.T) {
+	server, _ := startUploadRecorder(t)
+	recorder := &progressRecorder{}
+	api := openapiart.NewApi()
+	api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming().SetStreamChunkBytes(1000).SetUploadProgress(recorder.record)
+
+	data := bytes.Repeat([]byte("0123456789"), 450)
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	reported := recorder.reported()
+	assert.Len(t, reported, 5)
+	for i, progress := range reported {
+		assert.Equal(t, "UploadConfig", progress.Operation)
+		assert.Equal(t, i, progress.Chunk)
+		assert.Equal(t, uint64(len(data)), progress.Total)
+	}
+	assert.Equal(t, uint64(len(data)), reported[len(reported)-1].Sent)
+}
+
+func TestGrpcResumableUpload(t *testing.T) {
+	api, transport := interruptingApi(t, 2)
+	recorder := &progressRecorder{}
+	transport.SetResumableUploads(2).SetUploadProgress(recorder.record)
+	assert.Equal(t, 2, transport.ResumableUploads())
+
+	data := make([]byte, 5*megabyte)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	// compared with bytes.Equal to keep the failure output short
+	assert.True(t, bytes.Equal(data, grpcServer.Upload()))
+
+	// the upload restarts from the 2 chunks acknowledged by the server instead of from the start
+	reported := recorder.reported()
+	assert.Len(t, reported, 5)
+	for i, progress := range reported {
+		assert.Equal(t, i, progress.Chunk)
+		assert.Equal(t, uint64(i+1)*megabyte, progress.Sent)
+	}
+}
+
+func TestGrpcResumableUploadCancelledDuringBackoff(t *testing.T) {
+	api, transport := interruptingApi(t, 1)
+	transport.SetResumableUploads(2).SetRetryPolicy(openapiart.NewRetryPolicy().SetInitialBackoff(time.Hour))
+
+	// the upload is not restarted before the backoff of the retry policy
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := api.UploadConfigCtx(ctx, bytes.Repeat([]byte("a"), 3*megabyte))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+}
+
+func TestGrpcUploadNotResumable(t *testing.T) {
+	api, _ := interruptingApi(t, 1)
+	data := bytes.Repeat([]byte("a"), 3*megabyte)
+	_, err := api.UploadConfig(data)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+}
+
+// interruptedBody fails once failAfter bytes have been read
+type interruptedBody struct {
+	io.ReadCloser
+	failAfter int
+}
+
+func (b *interruptedBody) Read(p []byte) (int, error) {
+	if b.failAfter == 0 {
+		return 0, errors.New("connection reset")
+	}
+	if len(p) > b.failAfter {
+		p = p[:b.failAfter]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.failAfter -= n
+	return n, err
+}
+
+// uploadRequest returns a request of the resumable upload id sending body from offset, or querying its offset when negative
+func uploadRequest(id string, offset int, body io.Reader) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/config/upload", body)
+	r.Header.Set(openapiart.UploadIdMetadataKey, id)
+	if offset >= 0 {
+		r.Header.Set(openapiart.UploadOffsetMetadataKey, strconv.Itoa(offset))
+	}
+	return r
+}
+
+// receivedOffset returns the offset acknowledged by store for the upload id
+func receivedOffset(t *testing.T, store *openapiart.UploadStore, id string) string {
+	w := httptest.NewRecorder()
+	assert.False(t, store.ReceiveRequest(w, uploadRequest(id, -1, nil)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	return w.Header().Get(openapiart.UploadOffsetMetadataKey)
+}
+
+// receivePartially sends the first sent bytes of the 10 bytes long body of the upload id to store
+func receivePartially(t *testing.T, store *openapiart.UploadStore, id string, sent int) {
+	w := httptest.NewRecorder()
+	body := &interruptedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), failAfter: sent}
+	assert.False(t, store.ReceiveRequest(w, uploadRequest(id, 0, body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadStore(t *testing.T) {
+	store := openapiart.NewUploadStore().SetMaxBytes(10)
+	receivePartially(t, store, "a", 6)
+	assert.Equal(t, "6", receivedOffset(t, store, "a"))
+
+	// an upload is resumed from the offset acknowledged by the store only
+	w := httptest.NewRecorder()
+	assert.False(t, store.ReceiveRequest(w, uploadRequest("a", 0, strings.NewReader("0123456789"))))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "6", w.Header().Get(openapiart.UploadOffsetMetadataKey))
+	r := uploadRequest("a", 6, strings.NewReader("6789"))
+	assert.True(t, store.ReceiveRequest(httptest.NewRecorder(), r))
+	body, _ := io.ReadAll(r.Body)
+	assert.Equal(t, "0123456789", string(body))
+	assert.Equal(t, "0", receivedOffset(t, store, "a"))
+
+	// the least recently updated uploads are evicted beyond the maximum bytes
+	receivePartially(t, store, "b", 6)
+	receivePartially(t, store, "c", 6)
+	assert.Equal(t, "0", receivedOffset(t, store, "b"))
+	assert.Equal(t, "6", receivedOffset(t, store, "c"))
+	store.Discard("c")
+	assert.Equal(t, "0", receivedOffset(t, store, "c"))
+
+	// the upload receiving data is not evicted even when it holds more than the maximum bytes
+	r = uploadRequest("e", 0, strings.NewReader("0123456789"))
+	assert.True(t, openapiart.NewUploadStore().SetMaxBytes(4).ReceiveRequest(httptest.NewRecorder(), r))
+	body, _ = io.ReadAll(r.Body)
+	assert.Equal(t, "0123456789", string(body))
+
+	// the uploads are evicted once not updated within the ttl
+	store.SetTTL(time.Millisecond)
+	receivePartially(t, store, "d", 4)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, "0", receivedOffset(t, store, "d"))
+
+	// a body exceeding the size limit of the server is refused and not kept
+	w = httptest.NewRecorder()
+	r = uploadRequest("f", 0, strings.NewReader("0123456789"))
+	r.Body = http.MaxBytesReader(w, r.Body, 4)
+	assert.False(t, store.ReceiveRequest(w, r))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, "0", receivedOffset(t, store, "f"))
+
+	// a request without upload id is handled as is
+	r = httptest.NewRequest(http.MethodPost, "/api/config/upload", strings.NewReader("data"))
+	assert.True(t, store.ReceiveRequest(httptest.NewRecorder(), r))
+}
+
+func TestHttpResumableUpload(t *testing.T) {
+	router := NewMockHttpRouter()
+	var mutex sync.Mutex
+	interrupted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		interrupt := !interrupted && r.Header.Get(openapiart.UploadOffsetMetadataKey) != ""
+		interrupted = interrupted || interrupt
+		mutex.Unlock()
+		if !interrupt {
+			router.ServeHTTP(w, r)
+			return
+		}
+		// the connection breaks once the server has received part of the first upload
+		r.Body = &interruptedBody{ReadCloser: r.Body, failAfter: 20000}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	recorder := &progressRecorder{}
+	api := openapiart.NewApi()
+	transport := api.NewHttpTransport().SetLocation(server.URL).EnableHttpStreaming().SetStreamChunkBytes(1000).
+		SetResumableUploads(2).SetUploadProgress(recorder.record)
+	assert.Equal(t, 2, transport.ResumableUploads())
+
+	data := make([]byte, 50000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	_, err := api.UploadConfig(data)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Equal(data, httpServer.Upload()))
+
+	// the upload restarts from the bytes acknowledged by the server instead of from the start
+	reported := recorder.reported()
+	chunks := map[int]int{}
+	for _, progress := range reported {
+		chunks[progress.Chunk]++
+	}
+	assert.Equal(t, 1, chunks[0])
+	assert.Contains(t, reported, openapiart.UploadProgress{Operation: "UploadConfig", Sent: 21000, Total: uint64(len(data)), Chunk: 20})
+	assert.Equal(t, uint64(len(data)), reported[len(reported)-1].Sent)
+	assert.True(t, interrupted)
+}